@@ -3,18 +3,80 @@ package config
 
 import (
 	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v7"
 )
 
 // MainConfig with init data
 type MainConfig struct {
-	JwtKey string `env:"JWT_KEY,notEmpty" envDefault:"jwtSecretToken"`
-	Port   string `env:"PORT,notEmpty" envDefault:"8080"`
+	JwtKey      string `env:"JWT_KEY,notEmpty" envDefault:"jwtSecretToken"`
+	Port        string `env:"PORT,notEmpty" envDefault:"8080"`
+	ServiceName string `env:"SERVICE_NAME,notEmpty" envDefault:"proxy-service"`
+
+	RolePermissions     map[string]string `env:"ROLE_PERMISSIONS,notEmpty" envDefault:"admin:*,user:profile|accounts|prices|trading"`
+	DefaultRole         string            `env:"DEFAULT_ROLE,notEmpty" envDefault:"user"`
+	OwnershipDenyStatus int               `env:"OWNERSHIP_DENY_STATUS,notEmpty" envDefault:"404"`
+
+	RateLimits               map[string]string `env:"RATE_LIMITS,notEmpty" envDefault:"/auth/login:5/1m,/auth/signup:3/1m,/auth/refresh:10/1m,/getCurrentPrices:50/1s,/subscribe:10/1m,default:20/1s"`
+	RateLimitCleanupInterval time.Duration     `env:"RATE_LIMIT_CLEANUP_INTERVAL,notEmpty" envDefault:"1m"`
+	// TrustedProxies CIDRs of proxies whose X-Forwarded-For is trusted, without them client ip is taken from connection
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	BackendTimeout           time.Duration `env:"BACKEND_TIMEOUT,notEmpty" envDefault:"5s"`
+	BackendRetries           int           `env:"BACKEND_RETRIES" envDefault:"2"`
+	BackendRetryMinBackoff   time.Duration `env:"BACKEND_RETRY_MIN_BACKOFF,notEmpty" envDefault:"100ms"`
+	BackendRetryMaxBackoff   time.Duration `env:"BACKEND_RETRY_MAX_BACKOFF,notEmpty" envDefault:"1s"`
+	BackendIdempotentMethods []string      `env:"BACKEND_IDEMPOTENT_METHODS" envDefault:"UserById,GetAccount,GetPositionByID,GetUserPositions,GetCurrentPrices"`
+	BreakerFailureThreshold  int           `env:"BREAKER_FAILURE_THRESHOLD,notEmpty" envDefault:"5"`
+	BreakerHalfOpenRequests  int           `env:"BREAKER_HALF_OPEN_REQUESTS,notEmpty" envDefault:"1"`
+	BreakerOpenTimeout       time.Duration `env:"BREAKER_OPEN_TIMEOUT,notEmpty" envDefault:"30s"`
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT,notEmpty" envDefault:"15s"`
+
+	HealthCheckTimeout     time.Duration `env:"HEALTH_CHECK_TIMEOUT,notEmpty" envDefault:"2s"`
+	HealthRequiredBackends []string      `env:"HEALTH_REQUIRED_BACKENDS" envDefault:"user,payment,price,trading"`
+
+	TracingExporter     string  `env:"TRACING_EXPORTER,notEmpty" envDefault:"none"`
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT,notEmpty" envDefault:"localhost:4317"`
+	TracingOTLPInsecure bool    `env:"TRACING_OTLP_INSECURE" envDefault:"true"`
+	TracingFile         string  `env:"TRACING_FILE,notEmpty" envDefault:"traces.json"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
 
 	PriceServicePort string `env:"PRICE_SERVICE_PORT,notEmpty" envDefault:"4000"`
 	PriceServiceHost string `env:"PRICE_SERVICE_HOST,notEmpty" envDefault:"localhost"`
 
+	PriceStreamMinBackoff time.Duration `env:"PRICE_STREAM_MIN_BACKOFF,notEmpty" envDefault:"500ms"`
+	PriceStreamMaxBackoff time.Duration `env:"PRICE_STREAM_MAX_BACKOFF,notEmpty" envDefault:"30s"`
+	SlowConsumerPolicy    string        `env:"SLOW_CONSUMER_POLICY,notEmpty" envDefault:"drop-oldest"`
+	PriceMaxFrameRate     int           `env:"PRICE_MAX_FRAME_RATE,notEmpty" envDefault:"10"`
+	PriceCacheMaxAge      time.Duration `env:"PRICE_CACHE_MAX_AGE,notEmpty" envDefault:"5s"`
+	PriceCacheMaxSymbols  int           `env:"PRICE_CACHE_MAX_SYMBOLS,notEmpty" envDefault:"100"`
+	PriceHeartbeat        time.Duration `env:"PRICE_HEARTBEAT,notEmpty" envDefault:"15s"`
+	PricePinnedNames      []string      `env:"PRICE_PINNED_NAMES"`
+
+	CandleIntervals  []string `env:"CANDLE_INTERVALS,notEmpty" envDefault:"1s,1m,5m,1h"`
+	CandleRetention  int      `env:"CANDLE_RETENTION,notEmpty" envDefault:"500"`
+	CandleMaxSymbols int      `env:"CANDLE_MAX_SYMBOLS,notEmpty" envDefault:"100"`
+
+	PriceHistorySize       int `env:"PRICE_HISTORY_SIZE,notEmpty" envDefault:"3000"`
+	PriceHistoryMaxSymbols int `env:"PRICE_HISTORY_MAX_SYMBOLS,notEmpty" envDefault:"100"`
+	PriceResumeMaxReplay   int `env:"PRICE_RESUME_MAX_REPLAY,notEmpty" envDefault:"1000"`
+
+	PnLRefreshInterval time.Duration `env:"PNL_REFRESH_INTERVAL,notEmpty" envDefault:"5s"`
+
+	ThresholdMinDistancePercent float64 `env:"THRESHOLD_MIN_DISTANCE_PERCENT,notEmpty" envDefault:"0.1"`
+
+	TrailingStopsFile string `env:"TRAILING_STOPS_FILE,notEmpty" envDefault:"trailing_stops.json"`
+
+	WebsocketPingInterval   time.Duration `env:"WEBSOCKET_PING_INTERVAL,notEmpty" envDefault:"30s"`
+	WebsocketPongTimeout    time.Duration `env:"WEBSOCKET_PONG_TIMEOUT,notEmpty" envDefault:"60s"`
+	WebsocketWriteTimeout   time.Duration `env:"WEBSOCKET_WRITE_TIMEOUT,notEmpty" envDefault:"10s"`
+	WebsocketMaxMessageSize int64         `env:"WEBSOCKET_MAX_MESSAGE_SIZE,notEmpty" envDefault:"4096"`
+
 	PaymentServicePort string `env:"PAYMENT_SERVICE_PORT,notEmpty" envDefault:"2000"`
 	PaymentServiceHost string `env:"PAYMENT_SERVICE_HOST,notEmpty" envDefault:"localhost"`
 
@@ -29,10 +91,69 @@ type MainConfig struct {
 func NewMainConfig() (*MainConfig, error) {
 	mainConfig := &MainConfig{}
 
-	err := env.Parse(mainConfig)
+	err := env.ParseWithFuncs(mainConfig, map[reflect.Type]env.ParserFunc{
+		reflect.TypeOf(map[string]string{}): parseMap,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("config - NewMainConfig - Parse:%w", err)
 	}
 
+	err = mainConfig.validate()
+	if err != nil {
+		return nil, fmt.Errorf("config - NewMainConfig - validate: %w", err)
+	}
+
 	return mainConfig, nil
 }
+
+// validate check values env tags can't: intervals of tickers, timeouts and limits must be positive
+func (c *MainConfig) validate() error {
+	for name, d := range map[string]time.Duration{
+		"RATE_LIMIT_CLEANUP_INTERVAL": c.RateLimitCleanupInterval,
+		"PNL_REFRESH_INTERVAL":        c.PnLRefreshInterval,
+		"BACKEND_TIMEOUT":             c.BackendTimeout,
+		"BREAKER_OPEN_TIMEOUT":        c.BreakerOpenTimeout,
+		"WEBSOCKET_PING_INTERVAL":     c.WebsocketPingInterval,
+		"WEBSOCKET_PONG_TIMEOUT":      c.WebsocketPongTimeout,
+		"WEBSOCKET_WRITE_TIMEOUT":     c.WebsocketWriteTimeout,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive, got %v", name, d)
+		}
+	}
+	// breaker without half-open requests never closes again
+	for name, n := range map[string]int{
+		"BREAKER_FAILURE_THRESHOLD":  c.BreakerFailureThreshold,
+		"BREAKER_HALF_OPEN_REQUESTS": c.BreakerHalfOpenRequests,
+		"PRICE_CACHE_MAX_SYMBOLS":    c.PriceCacheMaxSymbols,
+		"PRICE_HISTORY_SIZE":         c.PriceHistorySize,
+		"PRICE_HISTORY_MAX_SYMBOLS":  c.PriceHistoryMaxSymbols,
+	} {
+		if n <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", name, n)
+		}
+	}
+	// denied ownership is reported as forbidden or as missing resource, never as success or server error
+	if c.OwnershipDenyStatus != http.StatusForbidden && c.OwnershipDenyStatus != http.StatusNotFound {
+		return fmt.Errorf("OWNERSHIP_DENY_STATUS must be %d or %d, got %d", http.StatusForbidden, http.StatusNotFound, c.OwnershipDenyStatus)
+	}
+	// read deadline is extended by pongs, so healthy peer must get ping before it expires
+	if c.WebsocketPongTimeout <= c.WebsocketPingInterval {
+		return fmt.Errorf("WEBSOCKET_PONG_TIMEOUT %v must be greater than WEBSOCKET_PING_INTERVAL %v",
+			c.WebsocketPongTimeout, c.WebsocketPingInterval)
+	}
+	return nil
+}
+
+// parseMap parse "key:value,key:value" into map, value starts after the first ":"
+func parseMap(value string) (interface{}, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key:value pair %q", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
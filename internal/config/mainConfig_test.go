@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// validConfig config passing validation, tests invalidate one value of it
+func validConfig() *MainConfig {
+	return &MainConfig{
+		RateLimitCleanupInterval: time.Minute,
+		PnLRefreshInterval:       5 * time.Second,
+		BackendTimeout:           5 * time.Second,
+		BreakerOpenTimeout:       30 * time.Second,
+		BreakerFailureThreshold:  5,
+		BreakerHalfOpenRequests:  1,
+		WebsocketPingInterval:    30 * time.Second,
+		WebsocketPongTimeout:     60 * time.Second,
+		WebsocketWriteTimeout:    10 * time.Second,
+		OwnershipDenyStatus:      404,
+		PriceCacheMaxSymbols:     100,
+		PriceHistorySize:         3000,
+		PriceHistoryMaxSymbols:   100,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *MainConfig)
+	}{
+		{name: "rate limit cleanup interval", invalidate: func(c *MainConfig) { c.RateLimitCleanupInterval = 0 }},
+		{name: "pnl refresh interval", invalidate: func(c *MainConfig) { c.PnLRefreshInterval = -time.Second }},
+		{name: "backend timeout", invalidate: func(c *MainConfig) { c.BackendTimeout = 0 }},
+		{name: "breaker open timeout", invalidate: func(c *MainConfig) { c.BreakerOpenTimeout = 0 }},
+		{name: "breaker failure threshold", invalidate: func(c *MainConfig) { c.BreakerFailureThreshold = 0 }},
+		{name: "breaker half-open requests", invalidate: func(c *MainConfig) { c.BreakerHalfOpenRequests = 0 }},
+		{name: "price cache max symbols", invalidate: func(c *MainConfig) { c.PriceCacheMaxSymbols = 0 }},
+		{name: "price history max symbols", invalidate: func(c *MainConfig) { c.PriceHistoryMaxSymbols = 0 }},
+		{name: "websocket ping interval", invalidate: func(c *MainConfig) { c.WebsocketPingInterval = 0 }},
+		{name: "websocket write timeout", invalidate: func(c *MainConfig) { c.WebsocketWriteTimeout = 0 }},
+		{name: "ownership deny success", invalidate: func(c *MainConfig) { c.OwnershipDenyStatus = 200 }},
+		{name: "ownership deny server error", invalidate: func(c *MainConfig) { c.OwnershipDenyStatus = 500 }},
+		{name: "websocket pong timeout", invalidate: func(c *MainConfig) { c.WebsocketPongTimeout = c.WebsocketPingInterval }},
+	}
+	if err := validConfig().validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.invalidate(c)
+			if c.validate() == nil {
+				t.Error("expected config to be rejected")
+			}
+		})
+	}
+}
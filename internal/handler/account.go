@@ -25,11 +25,12 @@ type AccountService interface {
 // Account handler
 type Account struct {
 	accountService AccountService
+	authorization  *Authorization
 }
 
 // NewAccountHandler new account handler
-func NewAccountHandler(s AccountService) *Account {
-	return &Account{accountService: s}
+func NewAccountHandler(s AccountService, a *Authorization) *Account {
+	return &Account{accountService: s, authorization: a}
 }
 
 // CreateAccount godoc
@@ -38,7 +39,7 @@ func NewAccountHandler(s AccountService) *Account {
 // @Tags         accounts
 // @Produce      json
 // @Success      201	{object}	model.Account
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      500	{object}	Problem
 // @Router       /createAccount [post]
 // @Security Bearer
 func (a *Account) CreateAccount(c echo.Context) (err error) {
@@ -46,11 +47,9 @@ func (a *Account) CreateAccount(c echo.Context) (err error) {
 
 	account, err := a.accountService.CreateAccount(c.Request().Context(), id)
 	if err != nil {
-		logrus.Error(fmt.Errorf("account - CreateAccount - CreateAccount: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("account - CreateAccount - CreateAccount: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, account)
@@ -63,7 +62,7 @@ func (a *Account) CreateAccount(c echo.Context) (err error) {
 // @Accept       json
 // @Produce      json
 // @Success      200	{object}	model.Account
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      500	{object}	Problem
 // @Router       /getUserAccount [get]
 // @Security Bearer
 func (a *Account) GetUserAccount(c echo.Context) error {
@@ -71,11 +70,9 @@ func (a *Account) GetUserAccount(c echo.Context) error {
 
 	account, err := a.accountService.GetAccount(c.Request().Context(), id)
 	if err != nil {
-		logrus.Error(fmt.Errorf("account - GetAccount - GetAccount: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("account - GetAccount - GetAccount: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, account)
@@ -95,7 +92,8 @@ type AmountRequest struct {
 // @Produce      json
 // @Param        amount	body 		AmountRequest  true  "Amount of operation"
 // @Success      200
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      404	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /increaseAmount [post]
 // @Security Bearer
 //
@@ -112,20 +110,21 @@ func (a *Account) IncreaseAmount(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("account - IncreaseAmount - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
+	}
+
+	err = a.authorization.account(c, amount.AccountID)
+	if err != nil {
+		err = fmt.Errorf("account - IncreaseAmount - account: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	err = a.accountService.IncreaseAmount(c.Request().Context(), amount.AccountID, amount.Amount)
 	if err != nil {
 		err = fmt.Errorf("account - IncreaseAmount - IncreaseAmount: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -139,7 +138,8 @@ func (a *Account) IncreaseAmount(c echo.Context) (err error) {
 // @Produce      json
 // @Param        amount	body  		AmountRequest  true  "Amount of operation"
 // @Success      200
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      404	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /decreaseAmount [post]
 // @Security Bearer
 //
@@ -156,20 +156,21 @@ func (a *Account) DecreaseAmount(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("account - DecreaseAmount - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
+	}
+
+	err = a.authorization.account(c, amount.AccountID)
+	if err != nil {
+		err = fmt.Errorf("account - DecreaseAmount - account: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	err = a.accountService.DecreaseAmount(c.Request().Context(), amount.AccountID, amount.Amount)
 	if err != nil {
 		err = fmt.Errorf("account - DecreaseAmount - IncreaseAmount: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -0,0 +1,81 @@
+// Package handler authorization
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// OwnershipService service interface for resource ownership checks
+//
+//go:generate mockery --name=OwnershipService --case=underscore --output=./mocks
+type OwnershipService interface {
+	CheckPosition(ctx context.Context, userID, positionID string) error
+	CheckAccount(ctx context.Context, userID, accountID string) error
+}
+
+// Authorization checks that resources from request belong to user from jwt
+type Authorization struct {
+	ownershipService OwnershipService
+	rbac             *RBAC
+
+	denyStatus int
+}
+
+// NewAuthorization new authorization, roles with positions.any or accounts.any permissions bypass ownership checks,
+// denyStatus is returned for resources of other users: 403 or 404 to hide their existence
+func NewAuthorization(s OwnershipService, rbac *RBAC, denyStatus int) *Authorization {
+	return &Authorization{ownershipService: s, rbac: rbac, denyStatus: denyStatus}
+}
+
+// position check that position belongs to user from jwt
+func (a *Authorization) position(c echo.Context, positionID string) error {
+	if a.rbac.Allowed(roleFromContext(c), model.PermissionPositionsAny) {
+		return nil
+	}
+	err := a.ownershipService.CheckPosition(c.Request().Context(), idFromContext(c), positionID)
+	if err != nil {
+		return a.deny(c, "position", err)
+	}
+	return nil
+}
+
+// account check that account belongs to user from jwt
+func (a *Authorization) account(c echo.Context, accountID string) error {
+	if a.rbac.Allowed(roleFromContext(c), model.PermissionAccountsAny) {
+		return nil
+	}
+	err := a.ownershipService.CheckAccount(c.Request().Context(), idFromContext(c), accountID)
+	if err != nil {
+		return a.deny(c, "account", err)
+	}
+	return nil
+}
+
+func (a *Authorization) deny(c echo.Context, resource string, err error) error {
+	if !errors.Is(err, model.ErrNotOwned) {
+		return fmt.Errorf("authorization - %s: %w", resource, err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"user":     idFromContext(c),
+		"resource": resource,
+		"path":     c.Path(),
+	}).Warn("access to resource of another user denied")
+
+	message := fmt.Sprintf("%s not found", resource)
+	if a.denyStatus == http.StatusForbidden {
+		message = fmt.Sprintf("access to %s denied", resource)
+	}
+	return &echo.HTTPError{
+		Code:     a.denyStatus,
+		Message:  message,
+		Internal: err,
+	}
+}
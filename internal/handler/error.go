@@ -0,0 +1,145 @@
+// Package handler error handler
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// problemContentType content type of error responses
+const problemContentType = "application/problem+json"
+
+// Problem RFC 7807 error body
+type Problem struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Not Found"`
+	Status    int    `json:"status" example:"404"`
+	Detail    string `json:"detail,omitempty" example:"position not found"`
+	Code      string `json:"code" example:"not_found"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// httpStatusFromGRPC http status for every grpc code returned by backends
+var httpStatusFromGRPC = map[codes.Code]int{ //nolint:gochecknoglobals // read-only lookup table
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.FailedPrecondition: http.StatusUnprocessableEntity,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// detailFromGRPC client-facing detail for every grpc code with client error status, backend messages aren't exposed
+var detailFromGRPC = map[codes.Code]string{ //nolint:gochecknoglobals // read-only lookup table
+	codes.InvalidArgument:    "invalid argument",
+	codes.OutOfRange:         "argument out of range",
+	codes.Unauthenticated:    "unauthenticated",
+	codes.PermissionDenied:   "permission denied",
+	codes.NotFound:           "resource not found",
+	codes.AlreadyExists:      "resource already exists",
+	codes.Aborted:            "request conflicts with current state, retry it",
+	codes.FailedPrecondition: "request can't be performed in current state",
+	codes.ResourceExhausted:  "resource exhausted",
+	codes.Unimplemented:      "not implemented",
+}
+
+// ErrorHandler echo error handler, translates handler and backend errors to problem+json response
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := problemFromError(err)
+	problem.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	c.Response().Header().Set(echo.HeaderContentType, problemContentType)
+	c.Response().WriteHeader(problem.Status)
+	if c.Request().Method == http.MethodHead {
+		return
+	}
+	err = json.NewEncoder(c.Response()).Encode(problem)
+	if err != nil {
+		logrus.Errorf("handler - ErrorHandler - Encode: %v", err)
+	}
+}
+
+func problemFromError(err error) *Problem {
+	if st, ok := grpcStatus(err); ok {
+		return problemFromGRPC(st)
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		if st, ok := grpcStatus(he.Internal); ok {
+			return problemFromGRPC(st)
+		}
+		return newProblem(he.Code, codeFromHTTP(he.Code), fmt.Sprint(he.Message))
+	}
+
+	return newProblem(http.StatusInternalServerError, codeFromHTTP(http.StatusInternalServerError), "")
+}
+
+// grpcStatus find grpc status in error chain
+func grpcStatus(err error) (*status.Status, bool) {
+	var se interface{ GRPCStatus() *status.Status }
+	if err == nil || !errors.As(err, &se) {
+		return nil, false
+	}
+	return se.GRPCStatus(), true
+}
+
+// problemFromGRPC problem with fixed detail of grpc code, backend message is only logged
+func problemFromGRPC(st *status.Status) *Problem {
+	code, ok := httpStatusFromGRPC[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	logrus.Warnf("handler - problemFromGRPC: backend returned %s: %s", st.Code(), st.Message())
+	return newProblem(code, codeFromGRPC(st.Code()), detailFromGRPC[st.Code()])
+}
+
+// newProblem problem with detail hidden for server errors
+func newProblem(code int, machineCode, detail string) *Problem {
+	if code >= http.StatusInternalServerError {
+		detail = ""
+	}
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: detail,
+		Code:   machineCode,
+	}
+}
+
+// codeFromGRPC snake case name of grpc code, e.g. "failed_precondition"
+func codeFromGRPC(c codes.Code) string {
+	name := c.String()
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+// codeFromHTTP snake case http status text, e.g. "too_many_requests"
+func codeFromHTTP(code int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(code)), " ", "_")
+}
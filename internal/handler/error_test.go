@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "backend message isn't exposed",
+			err:    status.Error(codes.NotFound, "sql: no rows in result set"),
+			status: http.StatusNotFound,
+			code:   "not_found",
+			detail: "resource not found",
+		},
+		{
+			name:   "wrapped grpc error",
+			err:    fmt.Errorf("trading - OpenPosition: %w", status.Error(codes.FailedPrecondition, "balance is 10")),
+			status: http.StatusUnprocessableEntity,
+			code:   "failed_precondition",
+			detail: "request can't be performed in current state",
+		},
+		{
+			name:   "grpc error of http error",
+			err:    &echo.HTTPError{Code: http.StatusBadRequest, Message: "bad", Internal: status.Error(codes.AlreadyExists, "user admin exists")},
+			status: http.StatusConflict,
+			code:   "already_exists",
+			detail: "resource already exists",
+		},
+		{
+			name:   "server error detail is hidden",
+			err:    status.Error(codes.Internal, "panic: nil pointer"),
+			status: http.StatusInternalServerError,
+			code:   "internal",
+		},
+		{
+			name:   "http error",
+			err:    echo.NewHTTPError(http.StatusBadRequest, "amount is required"),
+			status: http.StatusBadRequest,
+			code:   "bad_request",
+			detail: "amount is required",
+		},
+		{
+			name:   "unknown error",
+			err:    fmt.Errorf("dial tcp: connection refused"),
+			status: http.StatusInternalServerError,
+			code:   "internal_server_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := problemFromError(tt.err)
+			if problem.Status != tt.status || problem.Code != tt.code || problem.Detail != tt.detail {
+				t.Errorf("expected %d %s %q, got %d %s %q", tt.status, tt.code, tt.detail, problem.Status, problem.Code, problem.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package handler health handler
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthService service interface for health handler
+//
+//go:generate mockery --name=HealthService --case=underscore --output=./mocks
+type HealthService interface {
+	Liveness() *model.HealthReport
+	Readiness(ctx context.Context) *model.HealthReport
+}
+
+// Health handler
+type Health struct {
+	healthService HealthService
+}
+
+// NewHealthHandler new health handler
+func NewHealthHandler(s HealthService) *Health {
+	return &Health{healthService: s}
+}
+
+// Liveness godoc
+//
+// @Summary      gateway liveness
+// @Tags         health
+// @Produce      json
+// @Success      200	{object}	model.HealthReport
+// @Router       /healthz [get]
+func (h *Health) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.healthService.Liveness())
+}
+
+// Readiness godoc
+//
+// @Summary      gateway readiness with status of every backend
+// @Tags         health
+// @Produce      json
+// @Success      200	{object}	model.HealthReport
+// @Failure      503	{object}	model.HealthReport
+// @Router       /readyz [get]
+func (h *Health) Readiness(c echo.Context) error {
+	report := h.healthService.Readiness(c.Request().Context())
+	if report.Status != model.StatusUp {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,156 @@
+// Package handler positions pnl stream
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// framePnL pnl frame type
+const framePnL = "pnl"
+
+// positionsUnavailableReason websocket close reason for pnl stream without positions
+const positionsUnavailableReason = "positions are unavailable"
+
+// PositionsService service interface for pnl stream
+//
+//go:generate mockery --name=PositionsService --case=underscore --output=./mocks
+type PositionsService interface {
+	GetOpenPositions(ctx context.Context, userID string) ([]*model.Position, error)
+}
+
+// PnLFrame websocket frame with pnl of open position at updated price
+type PnLFrame struct {
+	Type string `json:"type" example:"pnl"`
+	*model.PositionPnL
+	Time time.Time `json:"ts"`
+}
+
+// pnlWriter client of pnl stream, price frames are written as pnl frames of open positions with their names
+type pnlWriter struct {
+	frameWriter
+
+	mu        sync.Mutex
+	positions map[string][]*model.Position
+}
+
+func (w *pnlWriter) write(event string, frame interface{}) bool {
+	price, ok := frame.(PriceFrame)
+	if !ok {
+		return w.frameWriter.write(event, frame)
+	}
+
+	w.mu.Lock()
+	positions := w.positions[price.Name]
+	w.mu.Unlock()
+	for _, position := range positions {
+		pnl := &model.PositionPnL{Position: position, PnL: model.NewPnL(position, price.Price)}
+		if !w.frameWriter.write(framePnL, PnLFrame{Type: framePnL, PositionPnL: pnl, Time: price.Time}) {
+			return false
+		}
+	}
+	return true
+}
+
+// set replace positions, returns their names
+func (w *pnlWriter) set(positions []*model.Position) []string {
+	byName := make(map[string][]*model.Position)
+	names := make([]string, 0, len(positions))
+	for _, position := range positions {
+		if _, ok := byName[position.Name]; !ok {
+			names = append(names, position.Name)
+		}
+		byName[position.Name] = append(byName[position.Name], position)
+	}
+
+	w.mu.Lock()
+	w.positions = byName
+	w.mu.Unlock()
+	return names
+}
+
+// PositionsPnL godoc
+//
+// @Summary      Subscribe for pnl of open positions
+// @Description  websocket, streams PnLFrame of every open position of user on update of its price and StatusFrame,
+// @Description  positions are reloaded every refresh interval, messages from client are ignored
+// @Tags         trading
+// @Produce      json
+// @Success      200
+// @Failure      500
+// @Router       /positions/pnl [get]
+// @Security Bearer
+func (p *Price) PositionsPnL(c echo.Context) error {
+	ws, ww, ok := p.accept(c)
+	if !ok {
+		return nil
+	}
+	defer ws.Close()
+	defer p.untrack()
+
+	ctx := c.Request().Context()
+	userID := idFromContext(c)
+	socketID := uuid.New()
+	subscriber := p.priceService.Subscribe(socketID)
+	defer p.priceService.DeleteSubscription(socketID)
+
+	w := &pnlWriter{frameWriter: ww}
+	err := p.refreshPositions(ctx, socketID, userID, w)
+	if err != nil {
+		logrus.Error(fmt.Errorf("price - PositionsPnL - refreshPositions: %w", err))
+		w.close(websocket.CloseInternalServerErr, positionsUnavailableReason)
+		return nil
+	}
+
+	out := make(chan []byte)
+	readDone := make(chan struct{})
+	defer close(readDone)
+	sendDone := make(chan struct{})
+	go p.getPrice(ws, out, readDone)
+	go func() {
+		defer close(sendDone)
+		p.pump(ctx, w, subscriber, nil, p.websocket.PingInterval)
+	}()
+
+	refresh := time.NewTicker(p.pnlRefreshInterval)
+	defer refresh.Stop()
+	for {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				return nil
+			}
+		case <-refresh.C:
+			err = p.refreshPositions(ctx, socketID, userID, w)
+			if err != nil {
+				// previous positions are streamed until the next refresh
+				logrus.Warnf("price - PositionsPnL - refreshPositions: %v", err)
+			}
+		case <-sendDone:
+			return nil
+		}
+	}
+}
+
+// refreshPositions reload open positions of user and subscribe for their prices,
+// prices of newly subscribed names are sent as snapshot
+func (p *Price) refreshPositions(ctx context.Context, socketID uuid.UUID, userID string, w *pnlWriter) error {
+	positions, err := p.positionsService.GetOpenPositions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("price - refreshPositions - GetOpenPositions: %w", err)
+	}
+	_, err = p.priceService.UpdateSubscription(ctx, socketID, w.set(positions))
+	if err != nil {
+		return fmt.Errorf("price - refreshPositions - UpdateSubscription: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Package handler portfolio handler
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// PortfolioService service interface for portfolio handler
+//
+//go:generate mockery --name=PortfolioService --case=underscore --output=./mocks
+type PortfolioService interface {
+	GetPortfolio(ctx context.Context, userID string) *model.Portfolio
+}
+
+// PortfolioResponse portfolio with problems of sections that failed to load, keyed by section:
+// account, positions or prices
+type PortfolioResponse struct {
+	*model.Portfolio
+	Errors map[string]*Problem `json:"errors,omitempty"`
+}
+
+// Portfolio handler
+type Portfolio struct {
+	portfolioService PortfolioService
+}
+
+// NewPortfolioHandler new portfolio handler
+func NewPortfolioHandler(s PortfolioService) *Portfolio {
+	return &Portfolio{portfolioService: s}
+}
+
+// GetPortfolio godoc
+//
+// @Summary      portfolio summary
+// @Description  balance, equity, exposure per symbol, realized and unrealized pnl and counts of positions,
+// @Description  sections that failed to load are missing and reported in errors
+// @Tags         trading
+// @Produce      json
+// @Success      200	{object}	PortfolioResponse
+// @Failure      500	{object}	Problem
+// @Router       /portfolio [get]
+// @Security Bearer
+func (p *Portfolio) GetPortfolio(c echo.Context) error {
+	portfolio := p.portfolioService.GetPortfolio(c.Request().Context(), idFromContext(c))
+
+	response := &PortfolioResponse{Portfolio: portfolio}
+	for section, err := range portfolio.Errors {
+		logrus.Warnf("portfolio - GetPortfolio - %s: %v", section, err)
+		if response.Errors == nil {
+			response.Errors = make(map[string]*Problem, len(portfolio.Errors))
+		}
+		problem := problemFromError(err)
+		if errors.Is(err, model.ErrPriceUnavailable) {
+			problem = newProblem(http.StatusServiceUnavailable, codeFromHTTP(http.StatusServiceUnavailable), "")
+		}
+		response.Errors[section] = problem
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -4,31 +4,48 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/net/websocket"
 )
 
 // PriceService service interface for price service
 //
 //go:generate mockery --name=PriceService --case=underscore --output=./mocks
 type PriceService interface {
-	GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.Price, error)
+	GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.CurrentPrice, error)
 
 	GetPrices() ([]*model.Price, error)
-	Subscribe(streamID uuid.UUID) chan *model.Price
-	UpdateSubscription(socketID uuid.UUID, names []string) error
+	Subscribe(streamID uuid.UUID) *repository.Subscriber
+	AddSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error)
+	RemoveSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error)
+	UpdateSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error)
+	GetSubscription(socketID uuid.UUID) ([]string, error)
+	ResumeSubscription(ctx context.Context, socketID uuid.UUID, last map[string]uint64) ([]string, error)
+	GetCandles(ctx context.Context, name, interval string, limit int) ([]*model.Candle, error)
+	GetHistory(ctx context.Context, name string, from, to time.Time) []*model.PriceTick
+	AddCandleSubscription(ctx context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error)
+	RemoveCandleSubscription(ctx context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error)
+	UpdateCandleSubscription(ctx context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error)
+	GetCandleSubscription(socketID uuid.UUID, interval string) ([]string, error)
+	SetConflation(socketID uuid.UUID, conflate bool) error
 	DeleteSubscription(streamID uuid.UUID) error
 }
 
-// PriceRequest websocket request
+// PriceRequest prices request
 type PriceRequest struct {
 	Names []string `json:"names" validate:"required,dive,alpha,gte=2,lte=25" example:"gold,google,tesla,oil"`
 }
@@ -38,21 +55,141 @@ type PriceResponse struct {
 	Prices []*model.Price `json:"prices"`
 }
 
+// PriceFrame websocket frame with snapshot or update of price, seq increases by one with every update of symbol,
+// snapshot requested from price service has no seq
+type PriceFrame struct {
+	Type string `json:"type" example:"update"`
+	*model.Price
+	Seq  uint64    `json:"seq,omitempty" example:"42"`
+	Time time.Time `json:"ts"`
+}
+
+// CandleFrame websocket frame with updated candle
+type CandleFrame struct {
+	Type string `json:"type" example:"candle"`
+	*model.Candle
+}
+
+// GapFrame websocket frame with ticks of resumed symbol that can't be replayed, it's followed by snapshot
+type GapFrame struct {
+	Type string `json:"type" example:"gap"`
+	*model.Gap
+}
+
+// StatusFrame websocket frame with price feed status
+type StatusFrame struct {
+	Type string `json:"type" example:"status"`
+	*model.FeedStatus
+}
+
+const (
+	// closeGoingAway websocket close code for server shutdown
+	closeGoingAway = websocket.CloseGoingAway
+	// shutdownReason websocket close reason for server shutdown
+	shutdownReason = "server is shutting down"
+	// closePolicyViolation websocket close code for subscriber disconnected by server policy
+	closePolicyViolation = websocket.ClosePolicyViolation
+)
+
+// WebsocketConfig websocket keepalive and limits, peer that doesn't answer ping within pong timeout is disconnected
+type WebsocketConfig struct {
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+}
+
 // Price handler
 type Price struct {
-	priceService PriceService
+	priceService     PriceService
+	positionsService PositionsService
 
 	val *validator.Validate
+
+	// frameInterval minimal interval between frames of conflating subscribers
+	frameInterval time.Duration
+	// heartbeatInterval interval of keep-alive messages of event streams
+	heartbeatInterval time.Duration
+	// pnlRefreshInterval interval of reloading positions of pnl streams
+	pnlRefreshInterval time.Duration
+
+	upgrader  websocket.Upgrader
+	websocket WebsocketConfig
+
+	// wg active streams, stop is closed on shutdown
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closing bool
+	stop    chan struct{}
 }
 
-// NewPriceHandler new price handler
-func NewPriceHandler(s PriceService) *Price {
-	return &Price{priceService: s, val: validator.New()}
+// NewPriceHandler new price handler, conflating subscribers get at most maxFrameRate frames per second,
+// non-positive rate doesn't limit them
+func NewPriceHandler(s PriceService, ps PositionsService, maxFrameRate int, heartbeatInterval, pnlRefreshInterval time.Duration,
+	ws WebsocketConfig) *Price {
+	var frameInterval time.Duration
+	if maxFrameRate > 0 {
+		frameInterval = time.Second / time.Duration(maxFrameRate)
+	}
+	return &Price{
+		priceService:       s,
+		positionsService:   ps,
+		val:                validator.New(),
+		frameInterval:      frameInterval,
+		heartbeatInterval:  heartbeatInterval,
+		pnlRefreshInterval: pnlRefreshInterval,
+		upgrader: websocket.Upgrader{
+			// clients are authenticated by jwt, not by origin
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		websocket: ws,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Shutdown close all websockets and event streams with reason and wait until their subscriptions are released
+func (p *Price) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closing {
+		p.closing = true
+		close(p.stop)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("price - Shutdown: %w", ctx.Err())
+	}
+}
+
+// track register stream as active, false if handler is shutting down
+func (p *Price) track() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closing {
+		return false
+	}
+	p.wg.Add(1)
+	return true
+}
+
+func (p *Price) untrack() {
+	p.wg.Done()
 }
 
 // Subscribe godoc
 //
 // @Summary      Subscribe for prices
+// @Description  websocket, accepts SubscriptionRequest messages, answers with AckFrame or ErrorFrame,
+// @Description  streams PriceFrame, CandleFrame, GapFrame and StatusFrame
 // @Tags         prices
 // @Accept       json
 // @Produce      json
@@ -61,79 +198,196 @@ func NewPriceHandler(s PriceService) *Price {
 // @Router       /subscribe [get]
 // @Security Bearer
 func (p *Price) Subscribe(c echo.Context) error {
-	h := websocket.Handler(func(ws *websocket.Conn) {
-		defer ws.Close()
+	ws, w, ok := p.accept(c)
+	if !ok {
+		return nil
+	}
+	defer ws.Close()
+	defer p.untrack()
 
-		socketID := uuid.New()
-		priceChan := p.priceService.Subscribe(socketID)
-		defer p.priceService.DeleteSubscription(socketID)
+	socketID := uuid.New()
+	subscriber := p.priceService.Subscribe(socketID)
+	defer p.priceService.DeleteSubscription(socketID)
 
-		out := make(chan *PriceRequest)
-		go getPrice(ws, out)
-		go sendPrice(ws, priceChan)
+	out := make(chan []byte)
+	readDone := make(chan struct{})
+	defer close(readDone)
+	ctx := c.Request().Context()
+	control := make(chan func() interface{}, controlBufferSize)
+	sendDone := make(chan struct{})
+	go p.getPrice(ws, out, readDone)
+	go func() {
+		defer close(sendDone)
+		p.pump(ctx, w, subscriber, control, p.websocket.PingInterval)
+	}()
 
-		for {
-			priceRequest, ok := <-out
+	for {
+		select {
+		case data, ok := <-out:
 			if !ok {
-				return
+				return nil
 			}
-			err := p.priceService.UpdateSubscription(socketID, priceRequest.Names)
-			if err != nil {
-				logrus.Errorf("price - Subscribe - UpdateSubscription: %v", err)
-				return
+			select {
+			case control <- func() interface{} { return p.handleRequest(ctx, socketID, data) }:
+			case <-sendDone:
+				return nil
 			}
+		case <-sendDone:
+			return nil
 		}
-	})
-	s := websocket.Server{Handler: h, Handshake: nil}
-	s.ServeHTTP(c.Response(), c.Request())
-	return nil
+	}
 }
 
-func sendPrice(ws *websocket.Conn, in chan *model.Price) {
-	for {
-		data, ok := <-in
-		if !ok {
-			return
-		}
+// accept upgrade request to websocket and register it as active stream, false if upgrade failed
+// or handler is shutting down
+func (p *Price) accept(c echo.Context) (*websocket.Conn, *wsWriter, bool) {
+	ws, err := p.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// upgrader has already written error response
+		logrus.Error(fmt.Errorf("price - accept - Upgrade: %w", err))
+		return nil, nil, false
+	}
+	w := &wsWriter{ws: ws, writeTimeout: p.websocket.WriteTimeout}
 
-		marshalData, err := json.Marshal(data)
+	if !p.track() {
+		w.close(closeGoingAway, shutdownReason)
+		return nil, nil, false
+	}
+	return ws, w, true
+}
+
+// getPrice read requests until peer is gone or done is closed, every pong extends read deadline
+func (p *Price) getPrice(ws *websocket.Conn, out chan []byte, done <-chan struct{}) {
+	defer close(out)
+	ws.SetReadLimit(p.websocket.MaxMessageSize)
+	_ = ws.SetReadDeadline(time.Now().Add(p.websocket.PongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(p.websocket.PongTimeout))
+	})
+
+	for {
+		_, data, err := ws.ReadMessage()
 		if err != nil {
-			logrus.Errorf("price - Subscribe - sendPrice - Marshal: %v", err)
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.Errorf("price - Subscribe - getPriceRequest - ReadMessage: %v", err)
+			}
 			return
 		}
-
-		err = websocket.Message.Send(ws, marshalData)
-		if err != nil {
-			logrus.Errorf("price - Subscribe - sendPriceResponse - Send: %v", err)
+		_ = ws.SetReadDeadline(time.Now().Add(p.websocket.PongTimeout))
+		select {
+		case out <- data:
+		case <-done:
 			return
 		}
 	}
 }
 
-func getPrice(ws *websocket.Conn, out chan *PriceRequest) {
-	for {
-		priceRequest := &PriceRequest{}
-		var data []byte
-		err := websocket.Message.Receive(ws, &data)
-		if err != nil {
-			close(out)
-			logrus.Errorf("price - Subscribe - getPriceRequest - Receive: %v", err)
-			return
-		}
+// wsWriter websocket client of price stream, every write must complete within write timeout
+type wsWriter struct {
+	ws           *websocket.Conn
+	writeTimeout time.Duration
+}
 
-		err = json.Unmarshal(data, priceRequest)
+func (w *wsWriter) write(_ string, frame interface{}) bool {
+	marshalData, err := json.Marshal(frame)
+	if err != nil {
+		logrus.Errorf("price - Subscribe - sendPrice - Marshal: %v", err)
+		return false
+	}
+
+	_ = w.ws.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	err = w.ws.WriteMessage(websocket.TextMessage, marshalData)
+	if err != nil {
+		logrus.Errorf("price - Subscribe - sendPriceResponse - WriteMessage: %v", err)
+		return false
+	}
+	return true
+}
+
+func (w *wsWriter) heartbeat() bool {
+	err := w.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.writeTimeout))
+	if err != nil {
+		logrus.Errorf("price - Subscribe - ping - WriteControl: %v", err)
+		return false
+	}
+	return true
+}
+
+// close write close frame with code and reason, then close connection
+func (w *wsWriter) close(code int, reason string) {
+	err := w.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(w.writeTimeout))
+	if err != nil {
+		logrus.Errorf("price - Subscribe - close - WriteControl: %v", err)
+	}
+	_ = w.ws.Close()
+}
+
+// Stream godoc
+//
+// @Summary      Stream prices
+// @Description  server-sent events with PriceFrame and StatusFrame, event name is frame type
+// @Tags         prices
+// @Produce      text/event-stream
+// @Param        names		query		string	true	"comma separated names"	example(gold,oil)
+// @Param        conflate	query		bool	false	"deliver only the latest price of every symbol"
+// @Success      200
+// @Failure      400		{object}	Problem
+// @Failure      503		{object}	Problem
+// @Router       /prices/stream [get]
+// @Security Bearer
+func (p *Price) Stream(c echo.Context) error {
+	names := strings.Split(c.QueryParam("names"), ",")
+	err := p.val.Var(names, "required,dive,alpha,gte=2,lte=25")
+	if err != nil {
+		logrus.Error(fmt.Errorf("price - Stream - Var: %w", err))
+		return &echo.HTTPError{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid names: %v", err)}
+	}
+	var conflate bool
+	if c.QueryParam("conflate") != "" {
+		conflate, err = strconv.ParseBool(c.QueryParam("conflate"))
 		if err != nil {
-			close(out)
-			logrus.Errorf("price - Subscribe - getPriceRequest - Unmarshal: %v", err)
-			return
+			logrus.Error(fmt.Errorf("price - Stream - ParseBool: %w", err))
+			return &echo.HTTPError{Code: http.StatusBadRequest, Message: "invalid conflate"}
 		}
-		out <- priceRequest
 	}
+
+	if !p.track() {
+		return &echo.HTTPError{Code: http.StatusServiceUnavailable, Message: shutdownReason}
+	}
+	defer p.untrack()
+
+	streamID := uuid.New()
+	subscriber := p.priceService.Subscribe(streamID)
+	defer p.priceService.DeleteSubscription(streamID)
+	err = p.priceService.SetConflation(streamID, conflate)
+	if err != nil {
+		err = fmt.Errorf("price - Stream - SetConflation: %w", err)
+		logrus.Error(err)
+		return err
+	}
+	ctx := c.Request().Context()
+	_, err = p.priceService.UpdateSubscription(ctx, streamID, names)
+	if err != nil {
+		err = fmt.Errorf("price - Stream - UpdateSubscription: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, eventStreamContentType)
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+
+	p.pump(ctx, &sseWriter{res: c.Response()}, subscriber, nil, p.heartbeatInterval)
+	return nil
 }
 
-// GetCurrentPriceResponse gcp response
+// GetCurrentPriceResponse gcp response, cached prices have their age
 type GetCurrentPriceResponse struct {
-	Prices map[string]*model.Price `json:"prices"`
+	Prices map[string]*model.CurrentPrice `json:"prices"`
 }
 
 // GetCurrentPrices godoc
@@ -144,7 +398,7 @@ type GetCurrentPriceResponse struct {
 // @Produce      json
 // @Param        names	body 		PriceRequest  true  "Prices list"
 // @Success      200   	object		GetCurrentPriceResponse
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      500	{object}	Problem
 // @Router       /getCurrentPrices	[post]
 // @Security Bearer
 func (p *Price) GetCurrentPrices(c echo.Context) (err error) {
@@ -159,21 +413,125 @@ func (p *Price) GetCurrentPrices(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("price - GetCurrentPrices - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	prices, err := p.priceService.GetCurrentPrices(c.Request().Context(), names.Names)
 	if err != nil {
 		err = fmt.Errorf("price - GetCurrentPrices - GetCurrentPrices: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	return c.JSON(http.StatusOK, GetCurrentPriceResponse{Prices: prices})
 }
+
+// CandlesRequest candles request, limit is 100 by default
+type CandlesRequest struct {
+	Name     string `query:"name" validate:"required,alpha,gte=2,lte=25" example:"gold"`
+	Interval string `query:"interval" validate:"required" example:"1m"`
+	Limit    int    `query:"limit" validate:"omitempty,gte=1,lte=1000" example:"100"`
+}
+
+// CandlesResponse candles of symbol, oldest first
+type CandlesResponse struct {
+	Candles []*model.Candle `json:"candles"`
+}
+
+// defaultCandlesLimit number of candles returned without limit in request
+const defaultCandlesLimit = 100
+
+// GetCandles godoc
+//
+// @Summary      get price candles
+// @Tags         prices
+// @Produce      json
+// @Param        name		query		string	true	"price name"	example(gold)
+// @Param        interval	query		string	true	"candle interval"	example(1m)
+// @Param        limit		query		int		false	"number of last candles"	example(100)
+// @Success      200		{object}	CandlesResponse
+// @Failure      400		{object}	Problem
+// @Failure      500		{object}	Problem
+// @Router       /prices/candles [get]
+// @Security Bearer
+func (p *Price) GetCandles(c echo.Context) (err error) {
+	request := &CandlesRequest{}
+	err = c.Bind(request)
+	if err != nil {
+		logrus.Error(fmt.Errorf("price - GetCandles - Bind: %w", err))
+		return err
+	}
+
+	err = c.Validate(request)
+	if err != nil {
+		err = fmt.Errorf("price - GetCandles - Validate: %w", err)
+		logrus.Error(err)
+		return err
+	}
+	if request.Limit == 0 {
+		request.Limit = defaultCandlesLimit
+	}
+
+	candles, err := p.priceService.GetCandles(c.Request().Context(), request.Name, request.Interval, request.Limit)
+	if errors.Is(err, model.ErrUnknownInterval) {
+		logrus.Error(fmt.Errorf("price - GetCandles - GetCandles: %w", err))
+		return &echo.HTTPError{Code: http.StatusBadRequest, Message: fmt.Sprintf("unknown interval %q", request.Interval)}
+	}
+	if err != nil {
+		err = fmt.Errorf("price - GetCandles - GetCandles: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, CandlesResponse{Candles: candles})
+}
+
+// HistoryRequest price history request, bounds are RFC 3339 times, missing bound isn't checked
+type HistoryRequest struct {
+	Name string    `query:"name" validate:"required,alpha,gte=2,lte=25" example:"gold"`
+	From time.Time `query:"from" example:"2023-03-01T10:00:00Z"`
+	To   time.Time `query:"to" example:"2023-03-01T10:05:00Z"`
+}
+
+// HistoryResponse ticks of symbol, oldest first
+type HistoryResponse struct {
+	Ticks []*model.PriceTick `json:"ticks"`
+}
+
+// GetHistory godoc
+//
+// @Summary      get recent price history
+// @Tags         prices
+// @Produce      json
+// @Param        name	query		string	true	"price name"	example(gold)
+// @Param        from	query		string	false	"RFC 3339 start time"
+// @Param        to		query		string	false	"RFC 3339 end time"
+// @Success      200	{object}	HistoryResponse
+// @Failure      400	{object}	Problem
+// @Failure      500	{object}	Problem
+// @Router       /prices/history [get]
+// @Security Bearer
+func (p *Price) GetHistory(c echo.Context) (err error) {
+	request := &HistoryRequest{}
+	err = c.Bind(request)
+	if err != nil {
+		logrus.Error(fmt.Errorf("price - GetHistory - Bind: %w", err))
+		return err
+	}
+
+	err = c.Validate(request)
+	if err != nil {
+		err = fmt.Errorf("price - GetHistory - Validate: %w", err)
+		logrus.Error(err)
+		return err
+	}
+	if !request.From.IsZero() && !request.To.IsZero() && request.To.Before(request.From) {
+		return &echo.HTTPError{Code: http.StatusBadRequest, Message: "to is before from"}
+	}
+
+	ticks := p.priceService.GetHistory(c.Request().Context(), request.Name, request.From, request.To)
+	if ticks == nil {
+		ticks = []*model.PriceTick{}
+	}
+	return c.JSON(http.StatusOK, HistoryResponse{Ticks: ticks})
+}
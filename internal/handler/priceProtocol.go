@@ -0,0 +1,161 @@
+// Package handler price websocket protocol
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// subscription actions, message without action replaces subscription
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+	ActionReplace     = "replace"
+	ActionList        = "list"
+	ActionResume      = "resume"
+)
+
+// subscription types
+const (
+	SubscriptionPrices  = "prices"
+	SubscriptionCandles = "candles"
+)
+
+// control frame types
+const (
+	frameAck   = "ack"
+	frameError = "error"
+)
+
+// controlBufferSize number of requests waiting to be handled by pump
+const controlBufferSize = 16
+
+// SubscriptionRequest websocket request, id is echoed in ack or error frame,
+// type is prices by default, candles subscription requires interval,
+// resume adds names of last seen sequence numbers to prices subscription and replays ticks missed since them,
+// conflate switches delivery of only the latest price of every symbol at most max frame rate times per second
+type SubscriptionRequest struct {
+	ID       string            `json:"id,omitempty" example:"1"`
+	Action   string            `json:"action,omitempty" validate:"omitempty,oneof=subscribe unsubscribe replace list resume" example:"subscribe"`
+	Type     string            `json:"type,omitempty" validate:"omitempty,oneof=prices candles" example:"prices"`
+	Interval string            `json:"interval,omitempty" validate:"required_if=Type candles" example:"1m"`
+	Names    []string          `json:"names" validate:"required_unless=Action list,dive,alpha,gte=2,lte=25" example:"gold,google,tesla,oil"`
+	Last     map[string]uint64 `json:"last,omitempty" validate:"required_if=Action resume"`
+	Conflate *bool             `json:"conflate,omitempty" example:"false"`
+}
+
+// AckFrame websocket frame confirming request, names are subscribed names of subscription type after it
+type AckFrame struct {
+	Type         string   `json:"type" example:"ack"`
+	ID           string   `json:"id,omitempty" example:"1"`
+	Action       string   `json:"action" example:"subscribe"`
+	Subscription string   `json:"subscription" example:"prices"`
+	Interval     string   `json:"interval,omitempty" example:"1m"`
+	Names        []string `json:"names" example:"gold,google"`
+}
+
+// ErrorFrame websocket frame rejecting request
+type ErrorFrame struct {
+	Type  string `json:"type" example:"error"`
+	ID    string `json:"id,omitempty" example:"1"`
+	Error string `json:"error" example:"invalid message"`
+}
+
+// handleRequest apply subscription request, returns ack or error frame
+func (p *Price) handleRequest(ctx context.Context, socketID uuid.UUID, data []byte) interface{} {
+	request := &SubscriptionRequest{}
+	err := json.Unmarshal(data, request)
+	if err != nil {
+		return ErrorFrame{Type: frameError, Error: fmt.Sprintf("invalid message: %v", err)}
+	}
+	if request.Action == ActionResume {
+		if request.Type == SubscriptionCandles {
+			return ErrorFrame{Type: frameError, ID: request.ID, Error: "resume is supported only for prices"}
+		}
+		request.Names = make([]string, 0, len(request.Last))
+		for name := range request.Last {
+			request.Names = append(request.Names, name)
+		}
+	}
+	err = p.val.Struct(request)
+	if err != nil {
+		return ErrorFrame{Type: frameError, ID: request.ID, Error: err.Error()}
+	}
+	if request.Action == "" {
+		request.Action = ActionReplace
+	}
+	if request.Type == "" {
+		request.Type = SubscriptionPrices
+	}
+
+	if request.Conflate != nil {
+		err = p.priceService.SetConflation(socketID, *request.Conflate)
+		if err != nil {
+			logrus.Errorf("price - handleRequest - SetConflation: %v", err)
+			return ErrorFrame{Type: frameError, ID: request.ID, Error: "subscription is closed"}
+		}
+	}
+
+	var names []string
+	if request.Type == SubscriptionCandles {
+		names, err = p.handleCandles(ctx, socketID, request)
+	} else {
+		names, err = p.handlePrices(ctx, socketID, request)
+	}
+	if errors.Is(err, model.ErrUnknownInterval) {
+		return ErrorFrame{Type: frameError, ID: request.ID, Error: fmt.Sprintf("unknown interval %q", request.Interval)}
+	}
+	if errors.Is(err, model.ErrResumeFailed) {
+		return ErrorFrame{Type: frameError, ID: request.ID, Error: model.ErrResumeFailed.Error()}
+	}
+	if err != nil {
+		logrus.Errorf("price - handleRequest - %s: %v", request.Action, err)
+		return ErrorFrame{Type: frameError, ID: request.ID, Error: "subscription is closed"}
+	}
+	if names == nil {
+		names = []string{}
+	}
+	return AckFrame{
+		Type:         frameAck,
+		ID:           request.ID,
+		Action:       request.Action,
+		Subscription: request.Type,
+		Interval:     request.Interval,
+		Names:        names,
+	}
+}
+
+func (p *Price) handlePrices(ctx context.Context, socketID uuid.UUID, request *SubscriptionRequest) ([]string, error) {
+	switch request.Action {
+	case ActionSubscribe:
+		return p.priceService.AddSubscription(ctx, socketID, request.Names)
+	case ActionUnsubscribe:
+		return p.priceService.RemoveSubscription(ctx, socketID, request.Names)
+	case ActionList:
+		return p.priceService.GetSubscription(socketID)
+	case ActionResume:
+		return p.priceService.ResumeSubscription(ctx, socketID, request.Last)
+	default:
+		return p.priceService.UpdateSubscription(ctx, socketID, request.Names)
+	}
+}
+
+func (p *Price) handleCandles(ctx context.Context, socketID uuid.UUID, request *SubscriptionRequest) ([]string, error) {
+	switch request.Action {
+	case ActionSubscribe:
+		return p.priceService.AddCandleSubscription(ctx, socketID, request.Interval, request.Names)
+	case ActionUnsubscribe:
+		return p.priceService.RemoveCandleSubscription(ctx, socketID, request.Interval, request.Names)
+	case ActionList:
+		return p.priceService.GetCandleSubscription(socketID, request.Interval)
+	default:
+		return p.priceService.UpdateCandleSubscription(ctx, socketID, request.Interval, request.Names)
+	}
+}
@@ -0,0 +1,170 @@
+// Package handler price stream pump
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// eventStreamContentType content type of server-sent events
+const eventStreamContentType = "text/event-stream"
+
+// frameWriter client of price stream
+type frameWriter interface {
+	// write send frame of event type, false if client is gone
+	write(event string, frame interface{}) bool
+	// heartbeat keep connection alive, false if client is gone
+	heartbeat() bool
+	// close end stream with websocket close code and reason
+	close(code int, reason string)
+}
+
+// pump write frames from subscriber and control frames to client until subscriber, client or handler is done,
+// changed prices of conflating subscriber are written at most once per frame interval,
+// the only writer of client connection. Control frames are made by pump itself, so ack of request is written
+// before snapshots that request has sent to subscriber
+func (p *Price) pump(ctx context.Context, w frameWriter, subscriber *repository.Subscriber, control <-chan func() interface{},
+	heartbeatInterval time.Duration) {
+	// frameC is armed only while changed symbols wait for the next frame, changed isn't watched meanwhile
+	var frame *time.Timer
+	defer func() {
+		if frame != nil {
+			frame.Stop()
+		}
+	}()
+	var frameC <-chan time.Time
+	changed := subscriber.Changed()
+	var nextFrame time.Time
+	var heartbeat <-chan time.Time
+	if heartbeatInterval > 0 {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			w.close(closeGoingAway, shutdownReason)
+			return
+		case <-subscriber.Done():
+			if reason := subscriber.Reason(); reason != "" {
+				w.close(closePolicyViolation, reason)
+			}
+			return
+		case <-heartbeat:
+			if !w.heartbeat() {
+				return
+			}
+		case handle := <-control:
+			if !w.write("", handle()) {
+				return
+			}
+		case data := <-subscriber.Messages():
+			if !writeMessage(w, data) {
+				return
+			}
+		case <-changed:
+			// symbols changed before the next frame stay in their slots and are sent with it
+			if wait := time.Until(nextFrame); wait > 0 {
+				if frame == nil {
+					frame = time.NewTimer(wait)
+				} else {
+					frame.Reset(wait)
+				}
+				frameC, changed = frame.C, nil
+				continue
+			}
+			if !writeChanged(w, subscriber) {
+				return
+			}
+			nextFrame = time.Now().Add(p.frameInterval)
+		case <-frameC:
+			frameC, changed = nil, subscriber.Changed()
+			// changes signalled while waiting are written with this frame
+			select {
+			case <-changed:
+			default:
+			}
+			if !writeChanged(w, subscriber) {
+				return
+			}
+			nextFrame = time.Now().Add(p.frameInterval)
+		}
+	}
+}
+
+// writeChanged write buffered messages and changed prices of subscriber
+func writeChanged(w frameWriter, subscriber *repository.Subscriber) bool {
+	for _, data := range subscriber.DrainChanged() {
+		if !writeMessage(w, data) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeMessage write price, candle or feed status frame
+func writeMessage(w frameWriter, data *model.PriceMessage) bool {
+	if data.Status != nil {
+		return w.write(data.Type, StatusFrame{Type: data.Type, FeedStatus: data.Status})
+	}
+	if data.Candle != nil {
+		return w.write(data.Type, CandleFrame{Type: data.Type, Candle: data.Candle})
+	}
+	if data.Gap != nil {
+		return w.write(data.Type, GapFrame{Type: data.Type, Gap: data.Gap})
+	}
+	return w.write(data.Type, PriceFrame{Type: data.Type, Price: data.Price, Seq: data.Seq, Time: data.Time})
+}
+
+// sseWriter server-sent events client of price stream, every event has increasing id
+type sseWriter struct {
+	res *echo.Response
+	id  uint64
+}
+
+func (w *sseWriter) write(event string, frame interface{}) bool {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		logrus.Errorf("price - Stream - Marshal: %v", err)
+		return false
+	}
+	w.id++
+	return w.send(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", w.id, event, data))
+}
+
+func (w *sseWriter) heartbeat() bool {
+	return w.send(": heartbeat\n\n")
+}
+
+func (w *sseWriter) close(_ int, reason string) {
+	data, err := json.Marshal(struct {
+		Reason string `json:"reason"`
+	}{Reason: reason})
+	if err != nil {
+		logrus.Errorf("price - Stream - Marshal: %v", err)
+		return
+	}
+	w.send(fmt.Sprintf("event: close\ndata: %s\n\n", data))
+}
+
+func (w *sseWriter) send(message string) bool {
+	_, err := w.res.Write([]byte(message))
+	if err != nil {
+		logrus.Errorf("price - Stream - Write: %v", err)
+		return false
+	}
+	w.res.Flush()
+	return true
+}
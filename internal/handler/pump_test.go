@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// recorder frame writer recording frames
+type recorder struct {
+	frames chan interface{}
+}
+
+func (r *recorder) write(_ string, frame interface{}) bool {
+	r.frames <- frame
+	return true
+}
+
+func (r *recorder) heartbeat() bool { return true }
+
+func (r *recorder) close(int, string) {}
+
+// snapshotService price service sending snapshot of every subscribed name
+type snapshotService struct {
+	PriceService
+	subscriber *repository.Subscriber
+}
+
+func (s *snapshotService) AddSubscription(_ context.Context, _ uuid.UUID, names []string) ([]string, error) {
+	for _, name := range names {
+		s.subscriber.Send(&model.PriceMessage{Type: model.FrameSnapshot, Price: &model.Price{Name: name}})
+	}
+	return names, nil
+}
+
+func TestPumpWritesAckBeforeSnapshots(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		subscriber := repository.NewSubscriber(10, model.SlowConsumerDropOldest)
+		p := NewPriceHandler(&snapshotService{subscriber: subscriber}, nil, 0, 0, 0, WebsocketConfig{})
+		w := &recorder{frames: make(chan interface{}, 10)}
+		control := make(chan func() interface{}, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		go p.pump(ctx, w, subscriber, control, 0)
+
+		control <- func() interface{} {
+			return p.handleRequest(ctx, uuid.New(), []byte(`{"action":"subscribe","names":["gold"]}`))
+		}
+		for _, want := range []string{frameAck, model.FrameSnapshot} {
+			select {
+			case frame := <-w.frames:
+				var got string
+				switch f := frame.(type) {
+				case AckFrame:
+					got = f.Type
+				case PriceFrame:
+					got = f.Type
+				}
+				if got != want {
+					t.Fatalf("expected %s frame, got %+v", want, frame)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected %s frame", want)
+			}
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,84 @@
+// Package handler rate limit middleware
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// rate limit headers
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// RateLimitService service interface for rate limit middleware
+//
+//go:generate mockery --name=RateLimitService --case=underscore --output=./mocks
+type RateLimitService interface {
+	Allow(ctx context.Context, route, key string) (*model.RateDecision, error)
+}
+
+// RateLimit middleware
+type RateLimit struct {
+	rateLimitService RateLimitService
+}
+
+// NewRateLimitHandler new rate limit middleware
+func NewRateLimitHandler(s RateLimitService) *RateLimit {
+	return &RateLimit{rateLimitService: s}
+}
+
+// ByIP limit requests per client ip
+func (r *RateLimit) ByIP() echo.MiddlewareFunc {
+	return r.limit(func(c echo.Context) string {
+		return "ip:" + c.RealIP()
+	})
+}
+
+// ByUser limit requests per user from jwt
+func (r *RateLimit) ByUser() echo.MiddlewareFunc {
+	return r.limit(func(c echo.Context) string {
+		return "user:" + idFromContext(c)
+	})
+}
+
+func (r *RateLimit) limit(key func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			decision, err := r.rateLimitService.Allow(c.Request().Context(), c.Path(), key(c))
+			if err != nil {
+				// store is unavailable, requests aren't limited until it's back
+				logrus.Error(fmt.Errorf("rateLimit - limit - Allow: %w", err))
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(headerRateLimitLimit, strconv.Itoa(decision.Limit))
+			header.Set(headerRateLimitRemaining, strconv.Itoa(decision.Remaining))
+			header.Set(headerRateLimitReset, ceilSeconds(decision.Reset))
+			if !decision.Allowed {
+				header.Set(echo.HeaderRetryAfter, ceilSeconds(decision.RetryAfter))
+				return &echo.HTTPError{
+					Code:    http.StatusTooManyRequests,
+					Message: "rate limit exceeded",
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,73 @@
+// Package handler role based access control
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// permissionsSeparator separator of permissions in role policy
+const permissionsSeparator = "|"
+
+// RBAC role based access control over jwt role claim
+type RBAC struct {
+	roles       map[string]map[string]bool
+	defaultRole string
+}
+
+// NewRBAC role to permissions policy, permissions of role are separated by "|" and "*" grants all of them,
+// roles missing in policy and empty role get permissions of default role
+func NewRBAC(rolePermissions map[string]string, defaultRole string) *RBAC {
+	roles := make(map[string]map[string]bool, len(rolePermissions))
+	for role, permissions := range rolePermissions {
+		roles[role] = make(map[string]bool)
+		for _, p := range strings.Split(permissions, permissionsSeparator) {
+			if p = strings.TrimSpace(p); p != "" {
+				roles[role][p] = true
+			}
+		}
+	}
+	return &RBAC{roles: roles, defaultRole: defaultRole}
+}
+
+// Allowed role has permission
+func (r *RBAC) Allowed(role, permission string) bool {
+	permissions, ok := r.roles[role]
+	if !ok {
+		permissions = r.roles[r.defaultRole]
+	}
+	return permissions[model.PermissionAll] || permissions[permission]
+}
+
+// Require middleware allowing request only if role from jwt has all permissions
+func (r *RBAC) Require(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role := roleFromContext(c)
+			for _, p := range permissions {
+				if r.Allowed(role, p) {
+					continue
+				}
+				logrus.WithFields(logrus.Fields{
+					"audit":      true,
+					"user":       idFromContext(c),
+					"role":       role,
+					"permission": p,
+					"method":     c.Request().Method,
+					"path":       c.Path(),
+					"ip":         c.RealIP(),
+				}).Warn("rbac - access denied")
+				return &echo.HTTPError{
+					Code:    http.StatusForbidden,
+					Message: "insufficient permissions",
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -19,7 +20,7 @@ import (
 type TradingService interface {
 	OpenPosition(ctx context.Context, position *model.Position) (*model.Position, error)
 	GetPositionByID(ctx context.Context, positionID string) (*model.Position, error)
-	GetUserPositions(ctx context.Context, userID string) ([]*model.Position, error)
+	GetUserPositionsPnL(ctx context.Context, userID string) ([]*model.PositionPnL, error)
 	SetStopLoss(ctx context.Context, positionID string, stopLoss float64) error
 	SetTakeProfit(ctx context.Context, positionID string, takeProfit float64) error
 	ClosePosition(ctx context.Context, positionID string) error
@@ -39,33 +40,47 @@ type SetThresholdRequest struct {
 // Trading handler
 type Trading struct {
 	tradingService TradingService
+	authorization  *Authorization
 
 	val *validator.Validate
 }
 
 // NewTradingHandler new trading handler
-func NewTradingHandler(s TradingService) *Trading {
-	return &Trading{tradingService: s, val: validator.New()}
+func NewTradingHandler(s TradingService, a *Authorization) *Trading {
+	return &Trading{tradingService: s, authorization: a, val: validator.New()}
 }
 
-// OpenPositionRequest open position request
+// OpenPositionRequest open position request, stop loss and take profit are optional
 type OpenPositionRequest struct {
 	User          string  `json:"user"`
 	Name          string  `json:"name" validate:"required,alpha,gte=2,lte=30"`
 	Amount        float64 `json:"amount" validate:"required,gte=0"`
 	ShortPosition bool    `json:"short_position"`
+	StopLoss      float64 `json:"stop_loss,omitempty" validate:"gte=0"`
+	TakeProfit    float64 `json:"take_profit,omitempty" validate:"gte=0"`
+}
+
+// OpenPositionResponse opened position, unprotected is set if its stop loss or take profit couldn't be set
+type OpenPositionResponse struct {
+	*model.Position
+	Unprotected bool   `json:"unprotected,omitempty"`
+	Warning     string `json:"warning,omitempty"`
 }
 
 // OpenPosition godoc
 //
 // @Summary      open new position
+// @Description  stop loss and take profit are checked against current price before position is opened,
+// @Description  if they can't be set position is closed with 424, if it can't be closed it's returned as unprotected
 // @Tags         trading
 // @Accept       json
 // @Produce      json
 // @Param        position	body     	OpenPositionRequest  true  "New position"
-// @Success      201		{object}	model.Position
-// @Failure      400		{object}	echo.HTTPError
-// @Failure      500		{object}	echo.HTTPError
+// @Success      201		{object}	OpenPositionResponse
+// @Failure      400		{object}	Problem
+// @Failure      422		{object}	Problem
+// @Failure      424		{object}	Problem
+// @Failure      500		{object}	Problem
 // @Router       /openPosition [post]
 // @Security Bearer
 func (t *Trading) OpenPosition(c echo.Context) error {
@@ -82,10 +97,7 @@ func (t *Trading) OpenPosition(c echo.Context) error {
 	if err != nil {
 		err = fmt.Errorf("trading - OpenPosition - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	position.User = id
@@ -94,16 +106,39 @@ func (t *Trading) OpenPosition(c echo.Context) error {
 		Name:          position.Name,
 		Amount:        position.Amount,
 		ShortPosition: position.ShortPosition,
+		StopLoss:      position.StopLoss,
+		TakeProfit:    position.TakeProfit,
 	})
-	if err != nil {
+	if errors.Is(err, model.ErrUnprotected) {
 		logrus.Error(fmt.Errorf("trading - OpenPosition - OpenPosition: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		return c.JSON(http.StatusCreated, &OpenPositionResponse{
+			Position:    positionResponse,
+			Unprotected: true,
+			Warning:     model.ErrUnprotected.Error(),
+		})
 	}
+	if err != nil {
+		err = fmt.Errorf("trading - OpenPosition - OpenPosition: %w", err)
+		logrus.Error(err)
+		return tradingError(err)
+	}
+
+	return c.JSON(http.StatusCreated, &OpenPositionResponse{Position: positionResponse})
+}
 
-	return c.JSON(http.StatusCreated, positionResponse)
+// tradingError http error for rejected thresholds, closed position and unknown price
+func tradingError(err error) error {
+	var thresholdErr *model.ThresholdError
+	switch {
+	case errors.As(err, &thresholdErr):
+		return &echo.HTTPError{Code: http.StatusUnprocessableEntity, Message: thresholdErr.Error(), Internal: err}
+	case errors.Is(err, model.ErrPositionClosed):
+		return &echo.HTTPError{Code: http.StatusFailedDependency, Message: model.ErrPositionClosed.Error(), Internal: err}
+	case errors.Is(err, model.ErrPriceUnavailable):
+		return &echo.HTTPError{Code: http.StatusServiceUnavailable, Message: model.ErrPriceUnavailable.Error(), Internal: err}
+	default:
+		return err
+	}
 }
 
 // GetPositionByID godoc
@@ -114,8 +149,9 @@ func (t *Trading) OpenPosition(c echo.Context) error {
 // @Produce      json
 // @Param        id		header   	string	true	"id"
 // @Success      200	{object}	model.Position
-// @Failure      403	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      403	{object}	Problem
+// @Failure      404	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /getPositionByID [get]
 // @Security Bearer
 //
@@ -123,13 +159,18 @@ func (t *Trading) OpenPosition(c echo.Context) error {
 func (t *Trading) GetPositionByID(c echo.Context) error {
 	request := c.Request().Header.Get("id")
 
+	err := t.authorization.position(c, request)
+	if err != nil {
+		err = fmt.Errorf("trading - GetPositionByID - position: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
 	positionResponse, err := t.tradingService.GetPositionByID(c.Request().Context(), request)
 	if err != nil {
-		logrus.Error(fmt.Errorf("trading - GetPositionByID - GetPositionByID: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("trading - GetPositionByID - GetPositionByID: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, positionResponse)
@@ -138,12 +179,13 @@ func (t *Trading) GetPositionByID(c echo.Context) error {
 // GetUserPositions godoc
 //
 // @Summary      getting all user positions
+// @Description  open positions have pnl at current market price, it's missing if prices are unavailable
 // @Tags         trading
 // @Accept       json
 // @Produce      json
-// @Success      200	{array}		model.Position
-// @Failure      400	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Success      200	{array}		model.PositionPnL
+// @Failure      400	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /getUserPositions [get]
 // @Security Bearer
 //
@@ -151,13 +193,11 @@ func (t *Trading) GetPositionByID(c echo.Context) error {
 func (t *Trading) GetUserPositions(c echo.Context) error {
 	id := idFromContext(c)
 
-	positionResponse, err := t.tradingService.GetUserPositions(c.Request().Context(), id)
+	positionResponse, err := t.tradingService.GetUserPositionsPnL(c.Request().Context(), id)
 	if err != nil {
-		logrus.Error(fmt.Errorf("trading - GetUserPositions - GetUserPositions: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("trading - GetUserPositions - GetUserPositionsPnL: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, positionResponse)
@@ -166,13 +206,16 @@ func (t *Trading) GetUserPositions(c echo.Context) error {
 // SetStopLoss godoc
 //
 // @Summary      set stop loss for position
+// @Description  rejected with 422 naming broken rule if it's on wrong side of current price or too close to it
 // @Tags         trading
 // @Accept       json
 // @Produce      json
 // @Param        id		body   	  	SetThresholdRequest  true  "ID and threshold of position"
 // @Success      200
-// @Failure      400	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      400	{object}	Problem
+// @Failure      404	{object}	Problem
+// @Failure      422	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /setStopLoss [post]
 // @Security Bearer
 //
@@ -189,19 +232,21 @@ func (t *Trading) SetStopLoss(c echo.Context) error {
 	if err != nil {
 		err = fmt.Errorf("trading - SetStopLoss - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
+	}
+
+	err = t.authorization.position(c, request.ID)
+	if err != nil {
+		err = fmt.Errorf("trading - SetStopLoss - position: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	err = t.tradingService.SetStopLoss(c.Request().Context(), request.ID, request.Amount)
 	if err != nil {
-		logrus.Error(fmt.Errorf("trading - SetStopLoss - SetStopLoss: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("trading - SetStopLoss - SetStopLoss: %w", err)
+		logrus.Error(err)
+		return tradingError(err)
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -210,13 +255,16 @@ func (t *Trading) SetStopLoss(c echo.Context) error {
 // SetTakeProfit godoc
 //
 // @Summary      set take profit for position
+// @Description  rejected with 422 naming broken rule if it's on wrong side of current or entry price or too close to current one
 // @Tags         trading
 // @Accept       json
 // @Produce      json
 // @Param        id		body   	  	SetThresholdRequest  true  "ID and threshold of position"
 // @Success      200	{object}	model.Position
-// @Failure      400	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      400	{object}	Problem
+// @Failure      404	{object}	Problem
+// @Failure      422	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /setTakeProfit [post]
 // @Security Bearer
 //
@@ -233,19 +281,21 @@ func (t *Trading) SetTakeProfit(c echo.Context) error {
 	if err != nil {
 		err = fmt.Errorf("trading - SetTakeProfit - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
+	}
+
+	err = t.authorization.position(c, request.ID)
+	if err != nil {
+		err = fmt.Errorf("trading - SetTakeProfit - position: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	err = t.tradingService.SetTakeProfit(c.Request().Context(), request.ID, request.Amount)
 	if err != nil {
-		logrus.Error(fmt.Errorf("trading - SetTakeProfit - SetTakeProfit: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("trading - SetTakeProfit - SetTakeProfit: %w", err)
+		logrus.Error(err)
+		return tradingError(err)
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -259,19 +309,25 @@ func (t *Trading) SetTakeProfit(c echo.Context) error {
 // @Produce      json
 // @Param        id		header   	string  true  "Position ID"
 // @Success      200
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      404	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /closePosition [post]
 // @Security Bearer
 func (t *Trading) ClosePosition(c echo.Context) error {
 	request := c.Request().Header.Get("id")
 
-	err := t.tradingService.ClosePosition(c.Request().Context(), request)
+	err := t.authorization.position(c, request)
+	if err != nil {
+		err = fmt.Errorf("trading - ClosePosition - position: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	err = t.tradingService.ClosePosition(c.Request().Context(), request)
 	if err != nil {
-		logrus.Error(fmt.Errorf("trading - ClosePosition - ClosePosition: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("trading - ClosePosition - ClosePosition: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+func TestTradingError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "threshold", err: fmt.Errorf("trading: %w", &model.ThresholdError{Rule: "stop_loss_side"}), status: http.StatusUnprocessableEntity},
+		{name: "position closed", err: fmt.Errorf("trading: %w", model.ErrPositionClosed), status: http.StatusFailedDependency},
+		{name: "price unavailable", err: fmt.Errorf("trading: %w", model.ErrPriceUnavailable), status: http.StatusServiceUnavailable},
+		{name: "other", err: fmt.Errorf("dial tcp: connection refused"), status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problem := problemFromError(tradingError(tt.err)); problem.Status != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, problem.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package handler trailing stop handler
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// TrailingStopService service interface for trailing stop handler
+//
+//go:generate mockery --name=TrailingStopService --case=underscore --output=./mocks
+type TrailingStopService interface {
+	Create(ctx context.Context, userID, positionID string, distance, percent float64) (*model.TrailingStop, error)
+	GetUserStops(ctx context.Context, userID string) []*model.TrailingStop
+	Cancel(ctx context.Context, positionID string) error
+}
+
+// CreateTrailingStopRequest trailing stop of position at distance from market price or percent of it
+type CreateTrailingStopRequest struct {
+	ID       string  `json:"id" validate:"required"`
+	Distance float64 `json:"distance,omitempty" validate:"required_without=Percent,excluded_with=Percent,gte=0"`
+	Percent  float64 `json:"percent,omitempty" validate:"required_without=Distance,excluded_with=Distance,gte=0,lt=100"`
+}
+
+// TrailingStop handler
+type TrailingStop struct {
+	trailingStopService TrailingStopService
+	authorization       *Authorization
+}
+
+// NewTrailingStopHandler new trailing stop handler
+func NewTrailingStopHandler(s TrailingStopService, a *Authorization) *TrailingStop {
+	return &TrailingStop{trailingStopService: s, authorization: a}
+}
+
+// CreateTrailingStop godoc
+//
+// @Summary      create trailing stop for position
+// @Description  stop loss of position follows market price at distance or percent of it and is never loosened,
+// @Description  existing trailing stop of position is replaced
+// @Tags         trading
+// @Accept       json
+// @Produce      json
+// @Param        stop	body		CreateTrailingStopRequest	true	"Position ID and distance or percent"
+// @Success      201	{object}	model.TrailingStop
+// @Failure      400	{object}	Problem
+// @Failure      404	{object}	Problem
+// @Failure      422	{object}	Problem
+// @Failure      500	{object}	Problem
+// @Router       /createTrailingStop [post]
+// @Security Bearer
+func (t *TrailingStop) CreateTrailingStop(c echo.Context) error {
+	request := &CreateTrailingStopRequest{}
+	err := c.Bind(request)
+	if err != nil {
+		logrus.Error(fmt.Errorf("trailingStop - CreateTrailingStop - Bind: %w", err))
+		return err
+	}
+
+	err = c.Validate(request)
+	if err != nil {
+		err = fmt.Errorf("trailingStop - CreateTrailingStop - Validate: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	err = t.authorization.position(c, request.ID)
+	if err != nil {
+		err = fmt.Errorf("trailingStop - CreateTrailingStop - position: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	stop, err := t.trailingStopService.Create(c.Request().Context(), idFromContext(c), request.ID, request.Distance, request.Percent)
+	if err != nil {
+		err = fmt.Errorf("trailingStop - CreateTrailingStop - Create: %w", err)
+		logrus.Error(err)
+		return tradingError(err)
+	}
+
+	return c.JSON(http.StatusCreated, stop)
+}
+
+// GetTrailingStops godoc
+//
+// @Summary      getting all user trailing stops
+// @Tags         trading
+// @Produce      json
+// @Success      200	{array}		model.TrailingStop
+// @Failure      500	{object}	Problem
+// @Router       /getTrailingStops [get]
+// @Security Bearer
+func (t *TrailingStop) GetTrailingStops(c echo.Context) error {
+	return c.JSON(http.StatusOK, t.trailingStopService.GetUserStops(c.Request().Context(), idFromContext(c)))
+}
+
+// CancelTrailingStop godoc
+//
+// @Summary      cancel trailing stop of position
+// @Description  stop loss already set by trailing stop stays
+// @Tags         trading
+// @Produce      json
+// @Param        id		header   	string  true  "Position ID"
+// @Success      200
+// @Failure      404	{object}	Problem
+// @Failure      500	{object}	Problem
+// @Router       /cancelTrailingStop [post]
+// @Security Bearer
+func (t *TrailingStop) CancelTrailingStop(c echo.Context) error {
+	request := c.Request().Header.Get("id")
+
+	err := t.authorization.position(c, request)
+	if err != nil {
+		err = fmt.Errorf("trailingStop - CancelTrailingStop - position: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	err = t.trailingStopService.Cancel(c.Request().Context(), request)
+	if errors.Is(err, model.ErrTrailingStopNotFound) {
+		return &echo.HTTPError{Code: http.StatusNotFound, Message: model.ErrTrailingStopNotFound.Error(), Internal: err}
+	}
+	if err != nil {
+		err = fmt.Errorf("trailingStop - CancelTrailingStop - Cancel: %w", err)
+		logrus.Error(err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, "")
+}
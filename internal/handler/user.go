@@ -64,8 +64,8 @@ type SignupResponse struct {
 // @Produce      json
 // @Param        user	body    	SignupRequest  true  "New user"
 // @Success      201	{object}	SignupResponse
-// @Failure      400	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      400	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /auth/signup [post]
 func (u *User) Signup(c echo.Context) (err error) {
 	user := &model.User{}
@@ -79,14 +79,10 @@ func (u *User) Signup(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("user - Signup - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 	if err = passwordvalidator.Validate(user.Password, passwordStrength); err != nil {
-		err = fmt.Errorf("user - Signup - Validate: %w", err)
-		logrus.Error(err)
+		logrus.Error(fmt.Errorf("user - Signup - Validate: %w", err))
 		return &echo.HTTPError{
 			Code:    http.StatusBadRequest,
 			Message: err.Error(),
@@ -97,11 +93,9 @@ func (u *User) Signup(c echo.Context) (err error) {
 	var userResponse *model.User
 	userResponse, tokenPair, err = u.userService.Signup(c.Request().Context(), user)
 	if err != nil {
-		logrus.Error(fmt.Errorf("user - Signup - Signup: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("user - Signup - Signup: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusCreated,
@@ -125,8 +119,8 @@ type LoginRequest struct {
 // @Produce      json
 // @Param        data		body    	LoginRequest	true	"login and password"
 // @Success      200		{object}	model.TokenPair
-// @Failure      400		{object}	echo.HTTPError
-// @Failure      500		{object}	echo.HTTPError
+// @Failure      400		{object}	Problem
+// @Failure      500		{object}	Problem
 // @Router       /auth/login [post]
 func (u *User) Login(c echo.Context) (err error) {
 	user := &LoginRequest{}
@@ -141,10 +135,7 @@ func (u *User) Login(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("user - Login - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	var tokenPair *model.TokenPair
@@ -152,10 +143,7 @@ func (u *User) Login(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("user - Login - Login: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	return c.JSON(http.StatusOK, tokenPair)
@@ -168,7 +156,7 @@ func (u *User) Login(c echo.Context) (err error) {
 // @Produce      json
 // @Param 		 Cookie 	header 		string  		true	"refresh token"
 // @Success      200		{object}	model.TokenPair
-// @Failure      500		{object}	echo.HTTPError
+// @Failure      500		{object}	Problem
 // @Router       /auth/refresh [get]
 func (u *User) Refresh(c echo.Context) error {
 	cookie, err := c.Cookie("refresh")
@@ -196,11 +184,9 @@ func (u *User) Refresh(c echo.Context) error {
 	var tokenPair *model.TokenPair
 	tokenPair, err = u.userService.Refresh(c.Request().Context(), id, refresh)
 	if err != nil {
-		logrus.Error(fmt.Errorf("user - Refresh - Refresh: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("user - Refresh - Refresh: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, tokenPair)
@@ -220,8 +206,8 @@ type UpdateRequest struct {
 // @Produce      json
 // @Param		 user	body	UpdateRequest	 true	"New user info"
 // @Success      200
-// @Failure      400	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      400	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /update [put]
 // @Security Bearer
 func (u *User) Update(c echo.Context) (err error) {
@@ -236,10 +222,7 @@ func (u *User) Update(c echo.Context) (err error) {
 	if err != nil {
 		err = fmt.Errorf("user - Update - Validate: %w", err)
 		logrus.Error(err)
-		return &echo.HTTPError{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
+		return err
 	}
 
 	id := idFromContext(c)
@@ -249,11 +232,9 @@ func (u *User) Update(c echo.Context) (err error) {
 		Age:   user.Age,
 	})
 	if err != nil {
-		logrus.Error(fmt.Errorf("user - Update - Update: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("user - Update - Update: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, "")
@@ -267,9 +248,10 @@ func (u *User) Update(c echo.Context) (err error) {
 // @Produce      json
 // @Param        id	 	header   	string		true  "id"
 // @Success      200	object		model.User
-// @Failure      403	{object}	echo.HTTPError
-// @Failure      500	{object}	echo.HTTPError
+// @Failure      403	{object}	Problem
+// @Failure      500	{object}	Problem
 // @Router       /userByID [get]
+// @Router       /admin/userByID [get]
 // @Security Bearer
 func (u *User) UserByID(c echo.Context) (err error) {
 	id := c.Request().Header.Get("id")
@@ -277,11 +259,9 @@ func (u *User) UserByID(c echo.Context) (err error) {
 	var user *model.User
 	user, err = u.userService.GetByID(c.Request().Context(), id)
 	if err != nil {
-		logrus.Error(fmt.Errorf("user - UserByID - GetByID: %w", err))
-		return &echo.HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		err = fmt.Errorf("user - UserByID - GetByID: %w", err)
+		logrus.Error(err)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, user)
@@ -293,6 +273,12 @@ func idFromContext(c echo.Context) (ID string) {
 	return claims.(*model.CustomClaims).ID
 }
 
+func roleFromContext(c echo.Context) (role string) {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims
+	return claims.(*model.CustomClaims).Role
+}
+
 func idFromToken(token string, keyFunc func(token *jwt.Token) (interface{}, error)) (id string, err error) {
 	claims := &model.CustomClaims{}
 
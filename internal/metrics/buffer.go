@@ -0,0 +1,48 @@
+// Package metrics subscribers buffers collector
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bufferCollector histogram of subscribers buffers fill ratio, calculated on every scrape
+type bufferCollector struct {
+	priceService PriceService
+	desc         *prometheus.Desc
+	buckets      []float64
+}
+
+func newBufferCollector(p PriceService) *bufferCollector {
+	return &bufferCollector{
+		priceService: p,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "price", "buffer_fill_ratio"),
+			"Fill ratio of websocket subscribers price buffers.",
+			nil, nil,
+		),
+		buckets: []float64{0.1, 0.25, 0.5, 0.75, 0.9, 1},
+	}
+}
+
+// Describe prometheus.Collector implementation
+func (b *bufferCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.desc
+}
+
+// Collect prometheus.Collector implementation
+func (b *bufferCollector) Collect(ch chan<- prometheus.Metric) {
+	fill := b.priceService.BufferFill()
+
+	var sum float64
+	counts := make(map[float64]uint64, len(b.buckets))
+	for _, f := range fill {
+		sum += f
+		for _, upper := range b.buckets {
+			if f <= upper {
+				counts[upper]++
+			}
+		}
+	}
+
+	ch <- prometheus.MustNewConstHistogram(b.desc, uint64(len(fill)), sum, counts)
+}
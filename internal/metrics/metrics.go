@@ -0,0 +1,175 @@
+// Package metrics prometheus metrics of gateway
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// namespace prefix of all gateway metrics
+const namespace = "proxy"
+
+// ListenersRepository price fan-out statistics
+//
+//go:generate mockery --name=ListenersRepository --case=underscore --output=./mocks
+type ListenersRepository interface {
+	Subscribers() int
+	Symbols() int
+	SlowConsumers() uint64
+}
+
+// PriceService subscribers buffers statistics
+//
+//go:generate mockery --name=PriceService --case=underscore --output=./mocks
+type PriceService interface {
+	BufferFill() []float64
+}
+
+// Metrics gateway prometheus collectors
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	grpcRequests *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+}
+
+// NewMetrics create registry with http and grpc collectors
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of handled http requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "handled_total",
+			Help:      "Number of grpc calls to backends by service, method and status code.",
+		}, []string{"service", "method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc_client",
+			Name:      "duration_seconds",
+			Help:      "Latency of grpc calls to backends by service and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.grpcRequests, m.grpcDuration,
+	)
+	return m
+}
+
+// Handler http handler exposing metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware echo middleware counting requests and their latency per route
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			start := time.Now()
+			if err = next(c); err != nil {
+				c.Error(err)
+			}
+
+			labels := prometheus.Labels{
+				"route":  c.Path(),
+				"method": c.Request().Method,
+				"code":   strconv.Itoa(c.Response().Status),
+			}
+			m.httpRequests.With(labels).Inc()
+			// websocket and event stream requests last as long as connection, their duration isn't a latency
+			if !c.IsWebSocket() && c.Response().Header().Get(echo.HeaderContentType) != "text/event-stream" {
+				m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+			}
+			return err
+		}
+	}
+}
+
+// UnaryClientInterceptor grpc interceptor measuring unary calls to backends
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observeGRPC(method, err, time.Since(start))
+		return err
+	}
+}
+
+// StreamClientInterceptor grpc interceptor counting opened streams to backends
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		m.observeGRPC(method, err, time.Since(start))
+		return stream, err
+	}
+}
+
+func (m *Metrics) observeGRPC(fullMethod string, err error, duration time.Duration) {
+	service, method := splitMethod(fullMethod)
+	m.grpcRequests.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	m.grpcDuration.WithLabelValues(service, method).Observe(duration.Seconds())
+}
+
+// splitMethod split "/package.Service/Method" into service and method
+func splitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// RegisterPriceStream register price fan-out collectors
+func (m *Metrics) RegisterPriceStream(l ListenersRepository, p PriceService) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "price",
+			Name:      "subscribers",
+			Help:      "Number of websocket subscribers with at least one subscribed symbol.",
+		}, func() float64 { return float64(l.Subscribers()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "price",
+			Name:      "symbols",
+			Help:      "Number of symbols subscribed by websocket subscribers.",
+		}, func() float64 { return float64(l.Symbols()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "price",
+			Name:      "slow_consumer_events_total",
+			Help:      "Number of prices sent to subscriber with full buffer and handled by slow consumer policy.",
+		}, func() float64 { return float64(l.SlowConsumers()) }),
+		newBufferCollector(p),
+	)
+}
@@ -0,0 +1,51 @@
+// Package model candle
+package model
+
+import "time"
+
+// OHLC open, high, low and close of price during candle interval
+type OHLC struct {
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Close float64 `json:"close"`
+}
+
+// Candle bar of selling and purchase prices of symbol, starting at Start and lasting Interval
+type Candle struct {
+	Name     string    `json:"name"`
+	Interval string    `json:"interval"`
+	Start    time.Time `json:"start"`
+	Selling  OHLC      `json:"selling"`
+	Purchase OHLC      `json:"purchase"`
+	Ticks    int       `json:"ticks"`
+}
+
+// Update add price tick to candle
+func (c *Candle) Update(price *Price) {
+	c.Selling.update(price.SellingPrice)
+	c.Purchase.update(price.PurchasePrice)
+	c.Ticks++
+}
+
+func (o *OHLC) update(price float64) {
+	if price > o.High {
+		o.High = price
+	}
+	if price < o.Low {
+		o.Low = price
+	}
+	o.Close = price
+}
+
+// NewCandle candle opened by price tick
+func NewCandle(price *Price, interval string, start time.Time) *Candle {
+	return &Candle{
+		Name:     price.Name,
+		Interval: interval,
+		Start:    start,
+		Selling:  OHLC{Open: price.SellingPrice, High: price.SellingPrice, Low: price.SellingPrice, Close: price.SellingPrice},
+		Purchase: OHLC{Open: price.PurchasePrice, High: price.PurchasePrice, Low: price.PurchasePrice, Close: price.PurchasePrice},
+		Ticks:    1,
+	}
+}
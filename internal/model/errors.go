@@ -0,0 +1,39 @@
+// Package model errors
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotOwned resource doesn't exist or belongs to another user
+var ErrNotOwned = errors.New("resource not found")
+
+// ErrUnknownInterval candle interval isn't aggregated
+var ErrUnknownInterval = errors.New("unknown candle interval")
+
+// ErrPriceUnavailable market price of symbol is unknown
+var ErrPriceUnavailable = errors.New("price is unavailable")
+
+// ErrPositionClosed position was opened, but its stop loss or take profit couldn't be set, so it was closed
+var ErrPositionClosed = errors.New("stop loss or take profit couldn't be set, position was closed")
+
+// ErrUnprotected position is open, but its stop loss or take profit couldn't be set and it couldn't be closed
+var ErrUnprotected = errors.New("stop loss or take profit couldn't be set and position couldn't be closed, it's open unprotected")
+
+// ThresholdError stop loss or take profit of position breaks rule at current price
+type ThresholdError struct {
+	Rule      string
+	Threshold string
+	Reason    string
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Rule, e.Threshold, e.Reason)
+}
+
+// ErrResumeFailed missed ticks couldn't be delivered to resumed subscriber because its buffer was full
+var ErrResumeFailed = errors.New("resume failed, subscriber buffer is full")
+
+// ErrTrailingStopNotFound position has no trailing stop
+var ErrTrailingStopNotFound = errors.New("trailing stop not found")
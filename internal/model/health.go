@@ -0,0 +1,24 @@
+// Package model health model
+package model
+
+// health statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DependencyStatus health of one gateway dependency
+type DependencyStatus struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	State    string `json:"state,omitempty"`
+	Health   string `json:"health,omitempty"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthReport gateway health with status of every dependency
+type HealthReport struct {
+	Status       string              `json:"status"`
+	Dependencies []*DependencyStatus `json:"dependencies,omitempty"`
+}
@@ -0,0 +1,15 @@
+// Package model permissions
+package model
+
+// permissions granted to roles
+const (
+	PermissionAll          = "*"
+	PermissionAdmin        = "admin"
+	PermissionProfile      = "profile"
+	PermissionUsersRead    = "users.read"
+	PermissionAccounts     = "accounts"
+	PermissionAccountsAny  = "accounts.any"
+	PermissionPrices       = "prices"
+	PermissionTrading      = "trading"
+	PermissionPositionsAny = "positions.any"
+)
@@ -0,0 +1,45 @@
+// Package model position pnl model
+package model
+
+// percent multiplier of ratio
+const percent = 100
+
+// PnL unrealized profit and loss of open position at market price, long position is closed at selling price
+// and short one at purchase price of market, distances are from market price to stop loss and take profit
+// and are positive while they aren't reached
+type PnL struct {
+	MarketPrice        float64  `json:"market_price"`
+	Absolute           float64  `json:"absolute"`
+	Percent            float64  `json:"percent"`
+	StopLossDistance   *float64 `json:"stop_loss_distance,omitempty"`
+	TakeProfitDistance *float64 `json:"take_profit_distance,omitempty"`
+}
+
+// PositionPnL position with unrealized pnl, pnl is missing for closed positions and positions without market price
+type PositionPnL struct {
+	*Position
+	PnL *PnL `json:"pnl,omitempty"`
+}
+
+// NewPnL pnl of open position at market price, long position is opened at purchase price
+// and short one at selling price
+func NewPnL(position *Position, price *Price) *PnL {
+	entry, market, direction := position.PurchasePrice, price.SellingPrice, 1.0
+	if position.ShortPosition {
+		entry, market, direction = position.SellingPrice, price.PurchasePrice, -1.0
+	}
+
+	pnl := &PnL{MarketPrice: market, Absolute: direction * (market - entry) * position.Amount}
+	if entry != 0 {
+		pnl.Percent = direction * (market - entry) / entry * percent
+	}
+	if position.StopLoss != 0 {
+		distance := direction * (market - position.StopLoss)
+		pnl.StopLossDistance = &distance
+	}
+	if position.TakeProfit != 0 {
+		distance := direction * (position.TakeProfit - market)
+		pnl.TakeProfitDistance = &distance
+	}
+	return pnl
+}
@@ -0,0 +1,38 @@
+// Package model portfolio model
+package model
+
+// portfolio sections, failed ones are reported in errors
+const (
+	PortfolioAccount   = "account"
+	PortfolioPositions = "positions"
+	PortfolioPrices    = "prices"
+)
+
+// Portfolio summary of user account and positions at market prices, equity is balance with unrealized pnl,
+// sections that failed to load are missing and their errors are in Errors
+type Portfolio struct {
+	Account   *Account          `json:"account,omitempty"`
+	Positions *PositionsSummary `json:"positions,omitempty"`
+	Equity    *float64          `json:"equity,omitempty"`
+	Errors    map[string]error  `json:"-"`
+}
+
+// PositionsSummary counts and pnl of user positions, realized pnl is of closed positions,
+// unrealized pnl and exposure values are missing without market prices
+type PositionsSummary struct {
+	Open          int         `json:"open"`
+	Closed        int         `json:"closed"`
+	RealizedPnL   float64     `json:"realized_pnl"`
+	UnrealizedPnL *float64    `json:"unrealized_pnl,omitempty"`
+	Exposure      []*Exposure `json:"exposure"`
+}
+
+// Exposure amount of symbol in open long and short positions, net is long minus short,
+// value is of all of them at market price they'd be closed at
+type Exposure struct {
+	Name  string   `json:"name"`
+	Long  float64  `json:"long"`
+	Short float64  `json:"short"`
+	Net   float64  `json:"net"`
+	Value *float64 `json:"value,omitempty"`
+}
@@ -14,3 +14,13 @@ type Position struct {
 	ShortPosition bool    `json:"short_position" validate:"required"`
 	Closed        int64   `json:"closed"`
 }
+
+// Open position isn't closed
+func (p *Position) Open() bool {
+	return p.Closed == 0
+}
+
+// RealizedPnL pnl of closed position
+func (p *Position) RealizedPnL() float64 {
+	return (p.SellingPrice - p.PurchasePrice) * p.Amount
+}
@@ -1,9 +1,73 @@
 // Package model models
 package model
 
+import "time"
+
 // Price info about one position
 type Price struct {
 	Name string
 	SellingPrice,
 	PurchasePrice float64
 }
+
+// CachedPrice price with time it was received from price stream and its sequence number
+type CachedPrice struct {
+	*Price
+	Received time.Time
+	Seq      uint64
+}
+
+// PriceTick price received from price stream at time, seq increases by one with every tick of symbol
+type PriceTick struct {
+	*Price
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+}
+
+// Gap ticks of symbol with sequence numbers in [From, To] that can't be replayed
+type Gap struct {
+	Name string `json:"name"`
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// CurrentPrice price with its source, age of cached price is time since it was received from price stream
+type CurrentPrice struct {
+	*Price
+	Cached bool  `json:"cached"`
+	AgeMS  int64 `json:"age_ms"`
+}
+
+// price feed statuses
+const (
+	FeedStale     = "stale"
+	FeedRecovered = "recovered"
+)
+
+// FeedStatus state of price stream from price service
+type FeedStatus struct {
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// price stream frame types
+const (
+	FrameSnapshot = "snapshot"
+	FrameUpdate   = "update"
+	FrameStatus   = "status"
+	FrameCandle   = "candle"
+	FrameGap      = "gap"
+)
+
+// PriceMessage message for price stream subscribers, contains snapshot or update of price with its sequence number
+// and server time, candle, replay gap or feed status
+type PriceMessage struct {
+	Type   string
+	Price  *Price
+	Seq    uint64
+	Time   time.Time
+	Candle *Candle
+	Gap    *Gap
+	Status *FeedStatus
+}
@@ -0,0 +1,19 @@
+// Package model rate limit model
+package model
+
+import "time"
+
+// RatePolicy token bucket of Limit tokens, fully refilled over Period
+type RatePolicy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// RateDecision result of taking token from bucket
+type RateDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
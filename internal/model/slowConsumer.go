@@ -0,0 +1,25 @@
+// Package model slow consumer policies
+package model
+
+// slow consumer policies, applied when subscriber buffer is full
+const (
+	// SlowConsumerDropOldest drop the oldest buffered message to make room for the new one
+	SlowConsumerDropOldest = "drop-oldest"
+	// SlowConsumerConflate keep only the latest price per symbol until subscriber catches up
+	SlowConsumerConflate = "conflate"
+	// SlowConsumerDisconnect close subscription with SlowConsumerReason
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// SlowConsumerReason close reason of subscriber disconnected by SlowConsumerDisconnect policy
+const SlowConsumerReason = "slow consumer: message buffer is full"
+
+// ValidSlowConsumerPolicy policy is one of known slow consumer policies
+func ValidSlowConsumerPolicy(policy string) bool {
+	switch policy {
+	case SlowConsumerDropOldest, SlowConsumerConflate, SlowConsumerDisconnect:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+// Package model trailing stop model
+package model
+
+import "time"
+
+// TrailingStop stop loss of position following market price at fixed distance or percent of price,
+// it's only moved when it gets tighter, stop loss is the last one set for position
+type TrailingStop struct {
+	PositionID    string    `json:"position_id"`
+	User          string    `json:"user"`
+	Name          string    `json:"name"`
+	ShortPosition bool      `json:"short_position"`
+	Distance      float64   `json:"distance,omitempty"`
+	Percent       float64   `json:"percent,omitempty"`
+	StopLoss      float64   `json:"stop_loss"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+}
+
+// Next stop loss at market price, long position is closed at selling price and short one at purchase price,
+// false if it isn't tighter than current one
+func (s *TrailingStop) Next(price *Price) (float64, bool) {
+	if s.ShortPosition {
+		stop := price.PurchasePrice + s.offset(price.PurchasePrice)
+		return stop, s.StopLoss == 0 || stop < s.StopLoss
+	}
+	stop := price.SellingPrice - s.offset(price.SellingPrice)
+	return stop, stop > 0 && stop > s.StopLoss
+}
+
+// offset distance of stop loss from market price
+func (s *TrailingStop) offset(market float64) float64 {
+	if s.Percent > 0 {
+		return market * s.Percent / percent
+	}
+	return s.Distance
+}
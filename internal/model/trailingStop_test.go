@@ -0,0 +1,30 @@
+package model
+
+import "testing"
+
+func TestTrailingStopNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		stop  TrailingStop
+		price Price
+		want  float64
+		moved bool
+	}{
+		{name: "long first stop", stop: TrailingStop{Distance: 5}, price: Price{SellingPrice: 100}, want: 95, moved: true},
+		{name: "long ratchets up", stop: TrailingStop{Distance: 5, StopLoss: 95}, price: Price{SellingPrice: 110}, want: 105, moved: true},
+		{name: "long never loosens", stop: TrailingStop{Distance: 5, StopLoss: 95}, price: Price{SellingPrice: 98}, want: 93},
+		{name: "long percent", stop: TrailingStop{Percent: 10}, price: Price{SellingPrice: 200}, want: 180, moved: true},
+		{name: "long stop below zero", stop: TrailingStop{Distance: 5}, price: Price{SellingPrice: 3}, want: -2},
+		{name: "short first stop", stop: TrailingStop{ShortPosition: true, Distance: 5}, price: Price{PurchasePrice: 100}, want: 105, moved: true},
+		{name: "short ratchets down", stop: TrailingStop{ShortPosition: true, Distance: 5, StopLoss: 105}, price: Price{PurchasePrice: 90}, want: 95, moved: true},
+		{name: "short never loosens", stop: TrailingStop{ShortPosition: true, Distance: 5, StopLoss: 105}, price: Price{PurchasePrice: 102}, want: 107},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop, moved := tt.stop.Next(&tt.price)
+			if stop != tt.want || moved != tt.moved {
+				t.Errorf("expected %v, %v, got %v, %v", tt.want, tt.moved, stop, moved)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package repository in-memory candle aggregator
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// candleInterval aggregated interval and its name from config
+type candleInterval struct {
+	name     string
+	duration time.Duration
+}
+
+// Candles rolling candles of at most maxSymbols symbols at every interval, retention newest candles are kept,
+// new symbol over limit evicts candles of the least recently updated one
+type Candles struct {
+	mu         sync.RWMutex
+	intervals  []candleInterval
+	retention  int
+	maxSymbols int
+	// bars symbol to interval to candles, oldest first
+	bars    map[string]map[string][]*model.Candle
+	recency *recency
+}
+
+// NewCandleRepository new candle aggregator, intervals are durations like "1s" or "5m"
+func NewCandleRepository(intervals []string, retention, maxSymbols int) (*Candles, error) {
+	if retention <= 0 {
+		return nil, fmt.Errorf("candles - NewCandleRepository: retention must be positive")
+	}
+	if maxSymbols <= 0 {
+		return nil, fmt.Errorf("candles - NewCandleRepository: max symbols must be positive")
+	}
+	c := &Candles{
+		retention:  retention,
+		maxSymbols: maxSymbols,
+		bars:       make(map[string]map[string][]*model.Candle),
+		recency:    newRecency(),
+	}
+	for _, name := range intervals {
+		d, err := time.ParseDuration(name)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("candles - NewCandleRepository: invalid interval %q", name)
+		}
+		c.intervals = append(c.intervals, candleInterval{name: name, duration: d})
+	}
+	return c, nil
+}
+
+// HasInterval interval is aggregated
+func (c *Candles) HasInterval(interval string) bool {
+	for _, i := range c.intervals {
+		if i.name == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// Add aggregate prices received at time, returns copies of updated candles
+func (c *Candles) Add(prices []*model.Price, at time.Time) []*model.Candle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated := make([]*model.Candle, 0, len(prices)*len(c.intervals))
+	for _, p := range prices {
+		symbol, ok := c.bars[p.Name]
+		if !ok {
+			if len(c.bars) >= c.maxSymbols {
+				evicted := c.recency.oldest()
+				delete(c.bars, evicted)
+				c.recency.remove(evicted)
+			}
+			symbol = make(map[string][]*model.Candle, len(c.intervals))
+			c.bars[p.Name] = symbol
+		}
+		c.recency.touch(p.Name)
+		for _, i := range c.intervals {
+			start := at.Truncate(i.duration)
+			bars := symbol[i.name]
+			if n := len(bars); n > 0 && bars[n-1].Start.Equal(start) {
+				bars[n-1].Update(p)
+			} else {
+				bars = c.appendBar(bars, model.NewCandle(p, i.name, start))
+				symbol[i.name] = bars
+			}
+			last := *bars[len(bars)-1]
+			updated = append(updated, &last)
+		}
+	}
+	return updated
+}
+
+// appendBar append candle dropping the oldest one over retention
+func (c *Candles) appendBar(bars []*model.Candle, bar *model.Candle) []*model.Candle {
+	if len(bars) < c.retention {
+		return append(bars, bar)
+	}
+	copy(bars, bars[1:])
+	bars[len(bars)-1] = bar
+	return bars
+}
+
+// Get copies of last limit candles of symbol at interval, oldest first
+func (c *Candles) Get(name, interval string, limit int) ([]*model.Candle, error) {
+	if !c.HasInterval(interval) {
+		return nil, fmt.Errorf("candles - Get: %w", model.ErrUnknownInterval)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bars := c.bars[name][interval]
+	if limit > 0 && len(bars) > limit {
+		bars = bars[len(bars)-limit:]
+	}
+	candles := make([]*model.Candle, len(bars))
+	for i, b := range bars {
+		bar := *b
+		candles[i] = &bar
+	}
+	return candles, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+func TestCandlesAggregation(t *testing.T) {
+	c, err := NewCandleRepository([]string{"1m"}, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{3, 5, 1, 4} {
+		c.Add([]*model.Price{{Name: "gold", SellingPrice: price}}, start.Add(time.Duration(i)*10*time.Second))
+	}
+
+	candles, err := c.Get("gold", "1m", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 1 {
+		t.Fatalf("expected 1 candle, got %d", len(candles))
+	}
+	want := model.OHLC{Open: 3, High: 5, Low: 1, Close: 4}
+	if candles[0].Selling != want || candles[0].Ticks != 4 || !candles[0].Start.Equal(start) {
+		t.Errorf("expected %+v of 4 ticks at %v, got %+v", want, start, candles[0])
+	}
+}
+
+func TestCandlesRetention(t *testing.T) {
+	c, err := NewCandleRepository([]string{"1s"}, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		c.Add([]*model.Price{{Name: "gold", SellingPrice: float64(i)}}, start.Add(time.Duration(i)*time.Second))
+	}
+
+	candles, _ := c.Get("gold", "1s", 0)
+	if len(candles) != 2 || candles[0].Selling.Open != 1 || candles[1].Selling.Open != 2 {
+		t.Errorf("expected the newest 2 candles, got %v", candles)
+	}
+	if candles, _ = c.Get("gold", "1s", 1); len(candles) != 1 || candles[0].Selling.Open != 2 {
+		t.Errorf("expected the newest candle over limit, got %v", candles)
+	}
+	if _, err = c.Get("gold", "1h", 0); !errors.Is(err, model.ErrUnknownInterval) {
+		t.Errorf("expected ErrUnknownInterval, got %v", err)
+	}
+}
+
+func TestCandlesMaxSymbols(t *testing.T) {
+	c, err := NewCandleRepository([]string{"1m"}, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add([]*model.Price{{Name: "gold"}, {Name: "oil"}}, time.Now())
+	c.Add([]*model.Price{{Name: "gold"}}, time.Now())
+	c.Add([]*model.Price{{Name: "tesla"}}, time.Now())
+
+	if candles, _ := c.Get("gold", "1m", 0); len(candles) == 0 {
+		t.Error("expected recently updated gold to be kept")
+	}
+	if candles, _ := c.Get("oil", "1m", 0); len(candles) != 0 {
+		t.Error("expected least recently updated oil to be evicted")
+	}
+	if candles, _ := c.Get("tesla", "1m", 0); len(candles) == 0 {
+		t.Error("expected tesla to be stored over symbols limit")
+	}
+}
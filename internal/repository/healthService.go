@@ -0,0 +1,63 @@
+// Package repository grpc health
+package repository
+
+import (
+	"context"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCHealth health of backend grpc connection
+type GRPCHealth struct {
+	name     string
+	required bool
+	conn     *grpc.ClientConn
+	client   healthProto.HealthClient
+}
+
+// NewGRPCHealthRepository grpc health repository constructor
+func NewGRPCHealthRepository(name string, required bool, conn *grpc.ClientConn) *GRPCHealth {
+	return &GRPCHealth{
+		name:     name,
+		required: required,
+		conn:     conn,
+		client:   healthProto.NewHealthClient(conn),
+	}
+}
+
+// Check connection state and grpc.health.v1 status when backend offers it
+func (h *GRPCHealth) Check(ctx context.Context) *model.DependencyStatus {
+	result := &model.DependencyStatus{
+		Name:     h.name,
+		Status:   model.StatusUp,
+		Required: h.required,
+	}
+
+	resp, err := h.client.Check(ctx, &healthProto.HealthCheckRequest{})
+	switch {
+	case err == nil:
+		result.Health = resp.Status.String()
+		if resp.Status != healthProto.HealthCheckResponse_SERVING {
+			result.Status = model.StatusDown
+		}
+	case status.Code(err) == codes.Unimplemented:
+		result.Health = "UNIMPLEMENTED"
+	default:
+		result.Status = model.StatusDown
+		result.Error = status.Convert(err).Message()
+	}
+
+	state := h.conn.GetState()
+	result.State = state.String()
+	if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+		result.Status = model.StatusDown
+	}
+
+	return result
+}
@@ -3,6 +3,7 @@ package repository
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/OVantsevich/proxy-service/internal/model"
 
@@ -10,12 +11,14 @@ import (
 )
 
 // Subscribers storing subscribers
-type Subscribers map[uuid.UUID]chan *model.Price
+type Subscribers map[uuid.UUID]*Subscriber
 
 // Listeners websocket for grpc stream
 type Listeners struct {
 	MU     sync.RWMutex
 	prices map[string]Subscribers
+
+	slowConsumers atomic.Uint64
 }
 
 // NewListenersRepository constructor
@@ -35,42 +38,80 @@ func (l *Listeners) GetPrices() []string {
 }
 
 // Update add new pairs: price-stream
-func (l *Listeners) Update(listenerID uuid.UUID, listenerChan chan *model.Price, prices []string) {
+func (l *Listeners) Update(listenerID uuid.UUID, subscriber *Subscriber, prices []string) {
 	l.MU.Lock()
 	for _, p := range prices {
 		cp, ok := l.prices[p]
 		if ok {
-			cp[listenerID] = listenerChan
+			cp[listenerID] = subscriber
 			continue
 		}
 		l.prices[p] = make(Subscribers)
-		l.prices[p][listenerID] = listenerChan
+		l.prices[p][listenerID] = subscriber
 	}
 	l.MU.Unlock()
 }
 
+// Names prices subscribed by listener
+func (l *Listeners) Names(listenerID uuid.UUID) []string {
+	l.MU.RLock()
+	defer l.MU.RUnlock()
+	var names []string
+	for name, p := range l.prices {
+		if _, ok := p[listenerID]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // Delete remove pairs: price-stream
 func (l *Listeners) Delete(listenerID uuid.UUID) {
 	l.MU.Lock()
-	for _, p := range l.prices {
+	for name, p := range l.prices {
 		delete(p, listenerID)
+		if len(p) == 0 {
+			delete(l.prices, name)
+		}
 	}
 	l.MU.Unlock()
 }
 
-// Send prices to streams
-func (l *Listeners) Send(prices []*model.Price) {
+// Send price ticks to streams, never blocks: slow subscribers are handled by their policy
+func (l *Listeners) Send(ticks []*model.PriceTick) {
 	l.MU.RLock()
-	for _, p := range prices {
-		for _, c := range l.prices[p.Name] {
-			select {
-			case c <- p:
-			default:
-				go func(goP *model.Price, goC chan *model.Price) {
-					goC <- goP
-				}(p, c)
+	for _, t := range ticks {
+		msg := &model.PriceMessage{Type: model.FrameUpdate, Price: t.Price, Seq: t.Seq, Time: t.Time}
+		for _, sub := range l.prices[t.Name] {
+			if !sub.Send(msg) {
+				l.slowConsumers.Add(1)
 			}
 		}
 	}
 	l.MU.RUnlock()
 }
+
+// Subscribers number of distinct subscribers
+func (l *Listeners) Subscribers() int {
+	l.MU.RLock()
+	defer l.MU.RUnlock()
+	ids := make(map[uuid.UUID]struct{})
+	for _, subs := range l.prices {
+		for id := range subs {
+			ids[id] = struct{}{}
+		}
+	}
+	return len(ids)
+}
+
+// Symbols number of subscribed prices
+func (l *Listeners) Symbols() int {
+	l.MU.RLock()
+	defer l.MU.RUnlock()
+	return len(l.prices)
+}
+
+// SlowConsumers number of prices sent to subscribers with full buffer
+func (l *Listeners) SlowConsumers() uint64 {
+	return l.slowConsumers.Load()
+}
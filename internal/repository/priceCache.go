@@ -0,0 +1,50 @@
+// Package repository last-value price cache
+package repository
+
+import (
+	"sync"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// PriceCache latest prices of at most maxSymbols symbols received from price stream,
+// new symbol over limit evicts price of the least recently updated one
+type PriceCache struct {
+	mu         sync.RWMutex
+	prices     map[string]*model.CachedPrice
+	recency    *recency
+	maxSymbols int
+}
+
+// NewPriceCacheRepository new empty price cache
+func NewPriceCacheRepository(maxSymbols int) *PriceCache {
+	return &PriceCache{prices: make(map[string]*model.CachedPrice), recency: newRecency(), maxSymbols: maxSymbols}
+}
+
+// Set store latest ticks
+func (pc *PriceCache) Set(ticks []*model.PriceTick) {
+	pc.mu.Lock()
+	for _, t := range ticks {
+		if _, ok := pc.prices[t.Name]; !ok && len(pc.prices) >= pc.maxSymbols {
+			evicted := pc.recency.oldest()
+			delete(pc.prices, evicted)
+			pc.recency.remove(evicted)
+		}
+		pc.prices[t.Name] = &model.CachedPrice{Price: t.Price, Received: t.Time, Seq: t.Seq}
+		pc.recency.touch(t.Name)
+	}
+	pc.mu.Unlock()
+}
+
+// Get cached prices by names, missing names are absent in result
+func (pc *PriceCache) Get(names []string) map[string]*model.CachedPrice {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	prices := make(map[string]*model.CachedPrice, len(names))
+	for _, name := range names {
+		if p, ok := pc.prices[name]; ok {
+			prices[name] = p
+		}
+	}
+	return prices
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+func TestPriceCacheMaxSymbols(t *testing.T) {
+	pc := NewPriceCacheRepository(2)
+	tick := func(name string, seq uint64) *model.PriceTick {
+		return &model.PriceTick{Price: &model.Price{Name: name}, Seq: seq, Time: time.Now()}
+	}
+	pc.Set([]*model.PriceTick{tick("gold", 1), tick("oil", 1)})
+	pc.Set([]*model.PriceTick{tick("gold", 2)})
+	pc.Set([]*model.PriceTick{tick("tesla", 1)})
+
+	cached := pc.Get([]string{"gold", "oil", "tesla"})
+	if len(cached) != 2 || cached["gold"].Seq != 2 || cached["tesla"] == nil {
+		t.Errorf("expected gold and tesla to be cached and least recently updated oil to be evicted, got %v", cached)
+	}
+}
@@ -0,0 +1,155 @@
+// Package repository recent price history
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// tickRing fixed size ring of price ticks, next is the position of the oldest tick once ring is full
+type tickRing struct {
+	ticks []model.PriceTick
+	next  int
+	full  bool
+}
+
+func (r *tickRing) add(tick model.PriceTick) {
+	r.ticks[r.next] = tick
+	r.next++
+	if r.next == len(r.ticks) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// ordered ticks from the oldest to the newest
+func (r *tickRing) ordered() []model.PriceTick {
+	if !r.full {
+		return r.ticks[:r.next]
+	}
+	ordered := make([]model.PriceTick, 0, len(r.ticks))
+	ordered = append(ordered, r.ticks[r.next:]...)
+	return append(ordered, r.ticks[:r.next]...)
+}
+
+// PriceHistory last size ticks of at most maxSymbols symbols, new symbol over limit evicts ticks and sequence number
+// of the least recently updated one, sequence numbers of new symbols start after the highest evicted one,
+// so they are never reused and resume from evicted sequence number gets gap
+type PriceHistory struct {
+	mu         sync.RWMutex
+	rings      map[string]*tickRing
+	recency    *recency
+	seq        map[string]uint64
+	evictedSeq uint64
+	size       int
+	maxSymbols int
+}
+
+// NewPriceHistoryRepository new empty price history
+func NewPriceHistoryRepository(size, maxSymbols int) *PriceHistory {
+	return &PriceHistory{
+		rings:      make(map[string]*tickRing),
+		recency:    newRecency(),
+		seq:        make(map[string]uint64),
+		size:       size,
+		maxSymbols: maxSymbols,
+	}
+}
+
+// Add store prices received at time, returns them as ticks with next sequence numbers of their symbols
+func (h *PriceHistory) Add(prices []*model.Price, at time.Time) []*model.PriceTick {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ticks := make([]*model.PriceTick, 0, len(prices))
+	for _, p := range prices {
+		if _, ok := h.seq[p.Name]; !ok {
+			h.track(p.Name)
+		}
+		h.seq[p.Name]++
+		h.recency.touch(p.Name)
+		tick := model.PriceTick{Price: p, Seq: h.seq[p.Name], Time: at}
+		ticks = append(ticks, &tick)
+		if h.size <= 0 {
+			continue
+		}
+
+		r, ok := h.rings[p.Name]
+		if !ok {
+			r = &tickRing{ticks: make([]model.PriceTick, h.size)}
+			h.rings[p.Name] = r
+		}
+		r.add(tick)
+	}
+	return ticks
+}
+
+// track start sequence of new symbol after evicted ones, the least recently updated symbol is evicted over limit
+func (h *PriceHistory) track(name string) {
+	if len(h.seq) >= h.maxSymbols {
+		evicted := h.recency.oldest()
+		if h.seq[evicted] > h.evictedSeq {
+			h.evictedSeq = h.seq[evicted]
+		}
+		delete(h.seq, evicted)
+		delete(h.rings, evicted)
+		h.recency.remove(evicted)
+	}
+	h.seq[name] = h.evictedSeq
+}
+
+// Since ticks of symbol with sequence numbers after seq, false if some of them aren't stored anymore
+// or seq is from before restart
+func (h *PriceHistory) Since(name string, seq uint64) ([]*model.PriceTick, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	latest := h.seq[name]
+	if seq == latest {
+		return nil, true
+	}
+	r, ok := h.rings[name]
+	if seq > latest || !ok {
+		return nil, false
+	}
+
+	ordered := r.ordered()
+	if ordered[0].Seq > seq+1 {
+		return nil, false
+	}
+	ticks := make([]*model.PriceTick, 0, latest-seq)
+	for _, t := range ordered {
+		if t.Seq > seq {
+			tick := t
+			ticks = append(ticks, &tick)
+		}
+	}
+	return ticks, true
+}
+
+// Latest sequence number of symbol, zero if symbol has no ticks
+func (h *PriceHistory) Latest(name string) uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.seq[name]
+}
+
+// Get ticks of symbol received in [from, to] from the oldest to the newest, zero bound isn't checked
+func (h *PriceHistory) Get(name string, from, to time.Time) []*model.PriceTick {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.rings[name]
+	if !ok {
+		return nil
+	}
+
+	var ticks []*model.PriceTick
+	for _, t := range r.ordered() {
+		if !from.IsZero() && t.Time.Before(from) || !to.IsZero() && t.Time.After(to) {
+			continue
+		}
+		tick := t
+		ticks = append(ticks, &tick)
+	}
+	return ticks
+}
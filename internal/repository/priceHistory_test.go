@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+func TestPriceHistoryWraparound(t *testing.T) {
+	h := NewPriceHistoryRepository(3, 10)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		h.Add([]*model.Price{{Name: "gold", SellingPrice: float64(i)}}, start.Add(time.Duration(i)*time.Second))
+	}
+
+	ticks := h.Get("gold", time.Time{}, time.Time{})
+	if len(ticks) != 3 {
+		t.Fatalf("expected 3 ticks, got %d", len(ticks))
+	}
+	for i, tick := range ticks {
+		if want := float64(i + 2); tick.SellingPrice != want {
+			t.Errorf("tick %d: expected price %v, got %v", i, want, tick.SellingPrice)
+		}
+	}
+
+	ticks = h.Get("gold", start.Add(3*time.Second), start.Add(4*time.Second))
+	if len(ticks) != 2 || ticks[0].SellingPrice != 3 || ticks[1].SellingPrice != 4 {
+		t.Errorf("expected ticks 3 and 4 in range, got %v", ticks)
+	}
+}
+
+func TestPriceHistoryMaxSymbols(t *testing.T) {
+	h := NewPriceHistoryRepository(3, 2)
+	h.Add([]*model.Price{{Name: "gold"}, {Name: "oil"}}, time.Now())
+	h.Add([]*model.Price{{Name: "gold"}}, time.Now())
+	h.Add([]*model.Price{{Name: "tesla"}}, time.Now())
+
+	if len(h.Get("gold", time.Time{}, time.Time{})) != 2 {
+		t.Error("expected recently updated gold to be kept")
+	}
+	if h.Get("oil", time.Time{}, time.Time{}) != nil {
+		t.Error("expected least recently updated oil to be evicted")
+	}
+	if len(h.Get("tesla", time.Time{}, time.Time{})) != 1 {
+		t.Error("expected tesla to be stored over symbols limit")
+	}
+	if _, ok := h.Since("oil", 1); ok {
+		t.Error("expected gap for evicted oil")
+	}
+	if len(h.seq) != 2 {
+		t.Errorf("expected sequence numbers of 2 symbols, got %d", len(h.seq))
+	}
+
+	// gold is evicted at seq 2, oil added again continues after it
+	ticks := h.Add([]*model.Price{{Name: "oil"}}, time.Now())
+	if ticks[0].Seq != 3 {
+		t.Errorf("expected oil to get sequence number after evicted ones, got %d", ticks[0].Seq)
+	}
+	if _, ok := h.Since("oil", 1); ok {
+		t.Error("expected gap for sequence number from before oil was evicted")
+	}
+}
+
+func TestPriceHistoryConcurrentReads(t *testing.T) {
+	const size, writes, readers = 16, 1000, 8
+	h := NewPriceHistoryRepository(size, 10)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			h.Add([]*model.Price{{Name: "gold", SellingPrice: float64(i)}}, start.Add(time.Duration(i)*time.Millisecond))
+		}
+	}()
+
+	errs := make(chan error, readers)
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				ticks := h.Get("gold", time.Time{}, time.Time{})
+				if len(ticks) > size {
+					errs <- fmt.Errorf("got %d ticks, more than ring size", len(ticks))
+					return
+				}
+				for j := 1; j < len(ticks); j++ {
+					if ticks[j].SellingPrice != ticks[j-1].SellingPrice+1 {
+						errs <- fmt.Errorf("ticks out of order: %v after %v", ticks[j].SellingPrice, ticks[j-1].SellingPrice)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPriceHistorySince(t *testing.T) {
+	h := NewPriceHistoryRepository(3, 10)
+	for i := 0; i < 5; i++ {
+		h.Add([]*model.Price{{Name: "gold", SellingPrice: float64(i)}}, time.Now())
+	}
+
+	ticks, ok := h.Since("gold", 3)
+	if !ok || len(ticks) != 2 || ticks[0].Seq != 4 || ticks[1].Seq != 5 {
+		t.Errorf("expected ticks 4 and 5, got %v, %v", ticks, ok)
+	}
+	if ticks, ok = h.Since("gold", 5); !ok || len(ticks) != 0 {
+		t.Errorf("expected nothing to replay, got %v, %v", ticks, ok)
+	}
+	if _, ok = h.Since("gold", 1); ok {
+		t.Error("expected gap for overwritten ticks")
+	}
+	if _, ok = h.Since("gold", 7); ok {
+		t.Error("expected gap for sequence number from before restart")
+	}
+}
@@ -4,22 +4,37 @@ package repository
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/OVantsevich/proxy-service/internal/model"
 
 	psProto "github.com/OVantsevich/Price-Service/proto"
+	"github.com/sirupsen/logrus"
 )
 
 // PriceService entity
 type PriceService struct {
 	ctx    context.Context
 	client psProto.PriceServiceClient
-	stream psProto.PriceService_GetPricesClient
+
+	streamMU sync.RWMutex
+	sendMU   sync.Mutex
+	stream   psProto.PriceService_GetPricesClient
+	alive    atomic.Bool
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	// backoff delay before the next reconnect attempt, kept between reconnects until ResetBackoff,
+	// only the stream reader reconnects, so it isn't guarded
+	backoff time.Duration
 }
 
 // NewPriceServiceRepository price service repository constructor
-func NewPriceServiceRepository(ctx context.Context, psp psProto.PriceServiceClient) (*PriceService, error) {
-	ps := &PriceService{client: psp, ctx: ctx}
+func NewPriceServiceRepository(ctx context.Context, psp psProto.PriceServiceClient, minBackoff, maxBackoff time.Duration) (*PriceService, error) {
+	ps := &PriceService{client: psp, ctx: ctx, minBackoff: minBackoff, maxBackoff: maxBackoff}
 	err := ps.subscribe()
 	if err != nil {
 		return nil, fmt.Errorf("priceService - NewPriceServiceRepository - subscribe : %w", err)
@@ -27,12 +42,68 @@ func NewPriceServiceRepository(ctx context.Context, psp psProto.PriceServiceClie
 	return ps, nil
 }
 
-func (ps *PriceService) subscribe() (err error) {
-	ps.stream, err = ps.client.GetPrices(ps.ctx)
+func (ps *PriceService) subscribe() error {
+	stream, err := ps.client.GetPrices(ps.ctx)
 	if err != nil {
 		return fmt.Errorf("priceService - Sebscribe - GetPrices: %w", err)
 	}
-	return
+	ps.streamMU.Lock()
+	ps.stream = stream
+	ps.streamMU.Unlock()
+	ps.alive.Store(true)
+	return nil
+}
+
+// Alive false after price stream fails and until it is reopened
+func (ps *PriceService) Alive() bool {
+	return ps.alive.Load()
+}
+
+func (ps *PriceService) currentStream() psProto.PriceService_GetPricesClient {
+	ps.streamMU.RLock()
+	defer ps.streamMU.RUnlock()
+	return ps.stream
+}
+
+// Reconnect reopen price stream, retrying with exponential backoff and jitter until success or ctx is done,
+// the first attempt after ResetBackoff is immediate, reconnect of stream that failed again waits for backoff
+func (ps *PriceService) Reconnect(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		if ps.backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("priceService - Reconnect: %w", ctx.Err())
+			case <-time.After(withJitter(ps.backoff)):
+			}
+		}
+
+		err := ps.subscribe()
+		ps.backoff *= 2
+		if ps.backoff < ps.minBackoff {
+			ps.backoff = ps.minBackoff
+		}
+		if ps.backoff > ps.maxBackoff {
+			ps.backoff = ps.maxBackoff
+		}
+		if err == nil {
+			return nil
+		}
+		logrus.Warnf("priceService - Reconnect - attempt %d: %v", attempt, err)
+	}
+}
+
+// ResetBackoff make the next reconnect immediate, called once reopened stream works
+func (ps *PriceService) ResetBackoff() {
+	ps.backoff = 0
+}
+
+// withJitter random delay from [d/2, d)
+func withJitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half)) //nolint:gosec // jitter doesn't need crypto rand
 }
 
 // GetCurrentPrices get current prices by names
@@ -47,8 +118,9 @@ func (ps *PriceService) GetCurrentPrices(ctx context.Context, names []string) (m
 
 // GetPrices get prices from price service
 func (ps *PriceService) GetPrices() ([]*model.Price, error) {
-	response, err := ps.stream.Recv()
+	response, err := ps.currentStream().Recv()
 	if err != nil {
+		ps.alive.Store(false)
 		return nil, fmt.Errorf("priceService - GetPrices - Recv: %w", err)
 	}
 	return pricesFromGRPC(response.Prices), nil
@@ -56,7 +128,9 @@ func (ps *PriceService) GetPrices() ([]*model.Price, error) {
 
 // UpdateSubscription subscribe for new prices
 func (ps *PriceService) UpdateSubscription(names []string) error {
-	err := ps.stream.Send(&psProto.GetPricesRequest{Names: names})
+	ps.sendMU.Lock()
+	defer ps.sendMU.Unlock()
+	err := ps.currentStream().Send(&psProto.GetPricesRequest{Names: names})
 	if err != nil {
 		return fmt.Errorf("priceService - UpdateSubscription - Send: %w", err)
 	}
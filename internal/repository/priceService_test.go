@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	psProto "github.com/OVantsevich/Price-Service/proto"
+	"google.golang.org/grpc"
+)
+
+// fakePriceClient price service client opening streams that are never read
+type fakePriceClient struct {
+	psProto.PriceServiceClient
+}
+
+func (fakePriceClient) GetPrices(context.Context, ...grpc.CallOption) (psProto.PriceService_GetPricesClient, error) {
+	return nil, nil
+}
+
+func TestPriceServiceReconnectBackoff(t *testing.T) {
+	const minBackoff = 40 * time.Millisecond
+	ps, err := NewPriceServiceRepository(context.Background(), fakePriceClient{}, minBackoff, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconnect := func() time.Duration {
+		start := time.Now()
+		if err := ps.Reconnect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+
+	if d := reconnect(); d >= minBackoff/2 {
+		t.Errorf("expected the first reconnect to be immediate, took %v", d)
+	}
+	if d := reconnect(); d < minBackoff/2 {
+		t.Errorf("expected reconnect of stream that failed again to wait for backoff, took %v", d)
+	}
+	ps.ResetBackoff()
+	if d := reconnect(); d >= minBackoff/2 {
+		t.Errorf("expected reconnect after reset to be immediate, took %v", d)
+	}
+}
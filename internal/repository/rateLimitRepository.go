@@ -0,0 +1,84 @@
+// Package repository in-memory rate limit store
+package repository
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// bucket token bucket state
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// RateLimitMemory in-memory token buckets
+type RateLimitMemory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewRateLimitMemoryRepository in-memory rate limit store, full buckets are removed every cleanup interval until ctx is done
+func NewRateLimitMemoryRepository(ctx context.Context, cleanup time.Duration) *RateLimitMemory {
+	r := &RateLimitMemory{buckets: make(map[string]*bucket)}
+	go r.cleanup(ctx, cleanup)
+	return r
+}
+
+// Take token from bucket with key
+func (r *RateLimitMemory) Take(_ context.Context, key string, policy model.RatePolicy) (*model.RateDecision, error) {
+	now := time.Now()
+	capacity := float64(policy.Limit)
+	rate := capacity / policy.Period.Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now, period: policy.Period}
+		r.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	decision := &model.RateDecision{Limit: policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = secondsToDuration((capacity - b.tokens) / rate)
+	return decision, nil
+}
+
+// cleanup remove buckets that were refilled completely
+func (r *RateLimitMemory) cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			for key, b := range r.buckets {
+				if now.Sub(b.updated) > b.period {
+					delete(r.buckets, key)
+				}
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
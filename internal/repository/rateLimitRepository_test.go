@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+func TestRateLimitMemoryBucket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRateLimitMemoryRepository(ctx, time.Minute)
+	policy := model.RatePolicy{Limit: 2, Period: 100 * time.Millisecond}
+
+	for i := 1; i >= 0; i-- {
+		decision, _ := r.Take(ctx, "login|1.2.3.4", policy)
+		if !decision.Allowed || decision.Remaining != i {
+			t.Fatalf("expected allowed with %d remaining, got %+v", i, decision)
+		}
+	}
+	decision, _ := r.Take(ctx, "login|1.2.3.4", policy)
+	if decision.Allowed || decision.RetryAfter <= 0 || decision.RetryAfter > 50*time.Millisecond {
+		t.Fatalf("expected rejection with retry after at most a token refill, got %+v", decision)
+	}
+	if decision, _ = r.Take(ctx, "login|5.6.7.8", policy); !decision.Allowed {
+		t.Error("expected bucket of other key to be full")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if decision, _ = r.Take(ctx, "login|1.2.3.4", policy); !decision.Allowed {
+		t.Errorf("expected token to be refilled, got %+v", decision)
+	}
+}
+
+func TestRateLimitMemoryCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRateLimitMemoryRepository(ctx, 10*time.Millisecond)
+	_, _ = r.Take(ctx, "login|1.2.3.4", model.RatePolicy{Limit: 1, Period: 10 * time.Millisecond})
+
+	time.Sleep(50 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buckets) != 0 {
+		t.Errorf("expected refilled bucket to be removed, got %d buckets", len(r.buckets))
+	}
+}
@@ -0,0 +1,34 @@
+// Package repository recency of symbols
+package repository
+
+// recency order of symbol updates, used to evict the least recently updated symbol over limit
+type recency struct {
+	clock   uint64
+	touched map[string]uint64
+}
+
+func newRecency() *recency {
+	return &recency{touched: make(map[string]uint64)}
+}
+
+// touch mark symbol as the most recently updated
+func (r *recency) touch(name string) {
+	r.clock++
+	r.touched[name] = r.clock
+}
+
+// oldest the least recently updated symbol, empty if there are none
+func (r *recency) oldest() string {
+	var name string
+	var oldest uint64
+	for n, t := range r.touched {
+		if name == "" || t < oldest {
+			name, oldest = n, t
+		}
+	}
+	return name
+}
+
+func (r *recency) remove(name string) {
+	delete(r.touched, name)
+}
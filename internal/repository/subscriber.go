@@ -0,0 +1,207 @@
+// Package repository price stream subscriber
+package repository
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// priceSlot latest price message of symbol, dirty until it's drained
+type priceSlot struct {
+	msg   *model.PriceMessage
+	dirty bool
+}
+
+// Subscriber buffered price stream of one websocket, messages channel is never closed,
+// so sending after subscription is deleted can't panic, Done is closed instead.
+// Conflating subscriber keeps only the latest price per symbol and delivers changed ones through DrainChanged
+type Subscriber struct {
+	policy     string
+	messages   chan *model.PriceMessage
+	conflating atomic.Bool
+
+	mu      sync.Mutex
+	slots   map[string]*priceSlot
+	order   []string
+	dirty   int
+	changed chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+	reason    string
+}
+
+// NewSubscriber new subscriber with buffer of size and slow consumer policy
+func NewSubscriber(size int, policy string) *Subscriber {
+	return &Subscriber{
+		policy:   policy,
+		messages: make(chan *model.PriceMessage, size),
+		slots:    make(map[string]*priceSlot),
+		changed:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Send deliver message without blocking, false if subscriber was slow and policy was applied
+func (s *Subscriber) Send(msg *model.PriceMessage) bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+
+	if msg.Price != nil {
+		if s.conflating.Load() {
+			s.conflate(msg)
+			return true
+		}
+		// prices conflated while buffer was full are moved back to it before newer ones to keep order
+		if !s.flush() {
+			s.conflate(msg)
+			return false
+		}
+	}
+	select {
+	case s.messages <- msg:
+		return true
+	default:
+	}
+
+	switch {
+	case s.policy == model.SlowConsumerDisconnect:
+		s.Close(model.SlowConsumerReason)
+	case s.policy == model.SlowConsumerConflate && msg.Price != nil:
+		s.conflate(msg)
+	default:
+		s.dropOldest(msg)
+	}
+	return false
+}
+
+// SetConflating switch conflating mode
+func (s *Subscriber) SetConflating(conflating bool) {
+	s.conflating.Store(conflating)
+}
+
+// conflate store price message in its symbol slot
+func (s *Subscriber) conflate(msg *model.PriceMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot, ok := s.slots[msg.Price.Name]
+	if !ok {
+		slot = &priceSlot{}
+		s.slots[msg.Price.Name] = slot
+		s.order = append(s.order, msg.Price.Name)
+	}
+	if !slot.dirty {
+		slot.dirty = true
+		s.dirty++
+	}
+	slot.msg = msg
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// flush move changed slots to buffer while it has room, false if some of them are left
+func (s *Subscriber) flush() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirty == 0 {
+		return true
+	}
+	for _, name := range s.order {
+		slot := s.slots[name]
+		if !slot.dirty {
+			continue
+		}
+		select {
+		case s.messages <- slot.msg:
+			slot.dirty = false
+			s.dirty--
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Subscriber) dropOldest(msg *model.PriceMessage) {
+	for {
+		select {
+		case s.messages <- msg:
+			return
+		default:
+		}
+		select {
+		case <-s.messages:
+		default:
+		}
+	}
+}
+
+// Messages buffered messages
+func (s *Subscriber) Messages() <-chan *model.PriceMessage {
+	return s.messages
+}
+
+// Changed signals that there are changed symbols, they should be read with DrainChanged
+func (s *Subscriber) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// DrainChanged buffered messages followed by latest prices of changed symbols, which are newer than all of them
+func (s *Subscriber) DrainChanged() []*model.PriceMessage {
+	var msgs []*model.PriceMessage
+	for {
+		select {
+		case msg := <-s.messages:
+			msgs = append(msgs, msg)
+			continue
+		default:
+		}
+		break
+	}
+
+	s.mu.Lock()
+	for _, name := range s.order {
+		if slot := s.slots[name]; slot.dirty {
+			slot.dirty = false
+			msgs = append(msgs, slot.msg)
+		}
+	}
+	s.dirty = 0
+	s.mu.Unlock()
+	return msgs
+}
+
+// Free number of messages buffer has room for
+func (s *Subscriber) Free() int {
+	return cap(s.messages) - len(s.messages)
+}
+
+// Fill buffer fill ratio
+func (s *Subscriber) Fill() float64 {
+	return float64(len(s.messages)) / float64(cap(s.messages))
+}
+
+// Close stop delivery, reason is empty if subscription was deleted by its websocket
+func (s *Subscriber) Close(reason string) {
+	s.closeOnce.Do(func() {
+		s.reason = reason
+		close(s.done)
+	})
+}
+
+// Done closed when subscriber is closed
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Reason close reason, must be read after Done is closed
+func (s *Subscriber) Reason() string {
+	return s.reason
+}
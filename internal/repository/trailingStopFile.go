@@ -0,0 +1,116 @@
+// Package repository file-backed trailing stop store
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// TrailingStopFile trailing stops kept in memory and written to json file on every change,
+// file is replaced atomically, so it's never left half-written
+type TrailingStopFile struct {
+	mu    sync.Mutex
+	path  string
+	stops map[string]*model.TrailingStop
+}
+
+// NewTrailingStopFileRepository trailing stop store loaded from file at path, missing file is empty store
+func NewTrailingStopFileRepository(path string) (*TrailingStopFile, error) {
+	f := &TrailingStopFile{path: path, stops: make(map[string]*model.TrailingStop)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trailingStopFile - NewTrailingStopFileRepository - ReadFile: %w", err)
+	}
+	err = json.Unmarshal(data, &f.stops)
+	if err != nil {
+		return nil, fmt.Errorf("trailingStopFile - NewTrailingStopFileRepository - Unmarshal: %w", err)
+	}
+	return f, nil
+}
+
+// Save create or replace trailing stop of position
+func (f *TrailingStopFile) Save(_ context.Context, stop *model.TrailingStop) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *stop
+	previous, ok := f.stops[stop.PositionID]
+	f.stops[stop.PositionID] = &stored
+	err := f.write()
+	if err != nil {
+		if ok {
+			f.stops[stop.PositionID] = previous
+		} else {
+			delete(f.stops, stop.PositionID)
+		}
+		return fmt.Errorf("trailingStopFile - Save - write: %w", err)
+	}
+	return nil
+}
+
+// Delete trailing stop of position
+func (f *TrailingStopFile) Delete(_ context.Context, positionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	previous, ok := f.stops[positionID]
+	if !ok {
+		return fmt.Errorf("trailingStopFile - Delete: %w", model.ErrTrailingStopNotFound)
+	}
+	delete(f.stops, positionID)
+	err := f.write()
+	if err != nil {
+		f.stops[positionID] = previous
+		return fmt.Errorf("trailingStopFile - Delete - write: %w", err)
+	}
+	return nil
+}
+
+// GetAll all trailing stops
+func (f *TrailingStopFile) GetAll(_ context.Context) ([]*model.TrailingStop, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stops := make([]*model.TrailingStop, 0, len(f.stops))
+	for _, s := range f.stops {
+		stop := *s
+		stops = append(stops, &stop)
+	}
+	return stops, nil
+}
+
+// write replace file with current stops through temporary file in the same directory
+func (f *TrailingStopFile) write() error {
+	data, err := json.Marshal(f.stops)
+	if err != nil {
+		return fmt.Errorf("trailingStopFile - write - Marshal: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("trailingStopFile - write - CreateTemp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("trailingStopFile - write: %w", err)
+	}
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("trailingStopFile - write - Rename: %w", err)
+	}
+	return nil
+}
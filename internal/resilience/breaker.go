@@ -0,0 +1,143 @@
+// Package resilience circuit breaker of backend
+package resilience
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State circuit breaker state
+type State int
+
+// circuit breaker states
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+// String state name
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker circuit breaker, opens after failureThreshold consecutive failures,
+// after openTimeout lets halfOpenRequests trial calls through and closes if all of them succeed
+type Breaker struct {
+	mu sync.Mutex
+
+	name     string
+	state    State
+	failures int
+	openedAt time.Time
+	trials   int
+	passed   int
+
+	failureThreshold int
+	halfOpenRequests int
+	openTimeout      time.Duration
+}
+
+// NewBreaker new closed circuit breaker
+func NewBreaker(name string, failureThreshold, halfOpenRequests int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		halfOpenRequests: halfOpenRequests,
+		openTimeout:      openTimeout,
+	}
+}
+
+// State current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow call can be made, every allowed call must be reported with Done
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.trials, b.passed = 0, 0
+	}
+	if b.trials >= b.halfOpenRequests {
+		return false
+	}
+	b.trials++
+	return true
+}
+
+// Done report result of allowed call, ctx is context of caller
+func (b *Breaker) Done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case ctx.Err() != nil && err != nil:
+		// caller gave up, backend health is unknown
+		if b.state == StateHalfOpen {
+			b.trials--
+		}
+	case isFailure(err):
+		b.failures++
+		if b.state == StateHalfOpen || b.state == StateClosed && b.failures >= b.failureThreshold {
+			b.openedAt = time.Now()
+			b.setState(StateOpen)
+		}
+	default:
+		b.failures = 0
+		if b.state != StateHalfOpen {
+			return
+		}
+		b.passed++
+		if b.passed >= b.halfOpenRequests {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+func (b *Breaker) setState(s State) {
+	if b.state == s {
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"backend": b.name,
+		"from":    b.state.String(),
+		"to":      s.String(),
+	}).Warn("circuit breaker state changed")
+	b.state = s
+}
+
+// isFailure error means that backend is unhealthy, business errors like NotFound don't count
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func call(t *testing.T, b *Breaker, err error) {
+	t.Helper()
+	if !b.Allow() {
+		t.Fatalf("expected call to be allowed in %s state", b.State())
+	}
+	b.Done(context.Background(), err)
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b := NewBreaker("test", 3, 1, time.Hour)
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	call(t, b, unavailable)
+	call(t, b, unavailable)
+	call(t, b, nil)
+	call(t, b, unavailable)
+	call(t, b, unavailable)
+	if b.State() != StateClosed {
+		t.Fatalf("expected success to reset failures, got %s", b.State())
+	}
+
+	call(t, b, unavailable)
+	if b.State() != StateOpen {
+		t.Fatalf("expected open after 3 consecutive failures, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Error("expected open breaker to reject calls")
+	}
+}
+
+func TestBreakerIgnoresBusinessErrors(t *testing.T) {
+	b := NewBreaker("test", 1, 1, time.Hour)
+
+	call(t, b, status.Error(codes.NotFound, "no user"))
+	call(t, b, status.Error(codes.InvalidArgument, "bad amount"))
+	if b.State() != StateClosed {
+		t.Errorf("expected business errors to keep breaker closed, got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoresCanceledCallers(t *testing.T) {
+	b := NewBreaker("test", 1, 1, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if !b.Allow() {
+		t.Fatal("expected closed breaker to allow call")
+	}
+	b.Done(ctx, status.Error(codes.DeadlineExceeded, "canceled"))
+	if b.State() != StateClosed {
+		t.Errorf("expected failure of canceled caller to be ignored, got %s", b.State())
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		trial error
+		want  State
+	}{
+		{name: "trial succeeds", trial: nil, want: StateClosed},
+		{name: "trial fails", trial: status.Error(codes.Internal, "down"), want: StateOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker("test", 1, 2, 10*time.Millisecond)
+			call(t, b, status.Error(codes.Unavailable, "down"))
+			time.Sleep(20 * time.Millisecond)
+
+			if !b.Allow() || b.State() != StateHalfOpen {
+				t.Fatalf("expected half-open trial after open timeout, got %s", b.State())
+			}
+			if !b.Allow() {
+				t.Fatal("expected the second trial to be allowed")
+			}
+			if b.Allow() {
+				t.Fatal("expected calls over half-open trials to be rejected")
+			}
+
+			b.Done(context.Background(), tt.trial)
+			if tt.want == StateClosed {
+				if b.State() != StateHalfOpen {
+					t.Fatalf("expected half-open until every trial succeeds, got %s", b.State())
+				}
+				b.Done(context.Background(), nil)
+			}
+			if b.State() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, b.State())
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package resilience timeouts, retries and circuit breaking of backend calls
+package resilience
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// healthServicePrefix methods of grpc health service, readiness checks must see backend itself, not its breaker
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// Backend guards unary calls to one backend
+type Backend struct {
+	name    string
+	breaker *Breaker
+
+	timeout    time.Duration
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	idempotent map[string]bool
+}
+
+// NewBackend new backend guard with own circuit breaker, settings are shared between backends
+func NewBackend(name string, cfg *config.MainConfig) *Backend {
+	idempotent := make(map[string]bool, len(cfg.BackendIdempotentMethods))
+	for _, m := range cfg.BackendIdempotentMethods {
+		idempotent[m] = true
+	}
+	return &Backend{
+		name:       name,
+		breaker:    NewBreaker(name, cfg.BreakerFailureThreshold, cfg.BreakerHalfOpenRequests, cfg.BreakerOpenTimeout),
+		timeout:    cfg.BackendTimeout,
+		retries:    cfg.BackendRetries,
+		minBackoff: cfg.BackendRetryMinBackoff,
+		maxBackoff: cfg.BackendRetryMaxBackoff,
+		idempotent: idempotent,
+	}
+}
+
+// Breaker circuit breaker of backend
+func (b *Backend) Breaker() *Breaker {
+	return b.breaker
+}
+
+// DialOption grpc option installing backend guard on connection,
+// streams aren't guarded: price stream reconnects with its own backoff
+func (b *Backend) DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(b.UnaryClientInterceptor())
+}
+
+// UnaryClientInterceptor grpc interceptor applying default timeout to every attempt,
+// failing fast while breaker is open and retrying idempotent methods with backoff, health checks aren't guarded
+func (b *Backend) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if strings.HasPrefix(method, healthServicePrefix) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		attempts := 1
+		if b.idempotent[methodName(method)] {
+			attempts += b.retries
+		}
+
+		delay := b.minBackoff
+		for attempt := 1; ; attempt++ {
+			if !b.breaker.Allow() {
+				return status.Errorf(codes.Unavailable, "%s backend is unavailable: circuit breaker is open", b.name)
+			}
+			attemptCtx, cancel := context.WithTimeout(ctx, b.timeout)
+			err := invoker(attemptCtx, method, req, reply, cc, opts...)
+			cancel()
+			b.breaker.Done(ctx, err)
+
+			if err == nil || attempt >= attempts || !retryable(err) {
+				return err
+			}
+			timer := time.NewTimer(withJitter(delay))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+
+			delay *= 2
+			if delay > b.maxBackoff {
+				delay = b.maxBackoff
+			}
+		}
+	}
+}
+
+// retryable error is transient
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// methodName method from "/package.Service/Method"
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// withJitter random delay from [d/2, d)
+func withJitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half)) //nolint:gosec // jitter doesn't need crypto rand
+}
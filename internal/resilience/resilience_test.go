@@ -0,0 +1,34 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInterceptorSkipsHealthChecks(t *testing.T) {
+	b := NewBackend("test", &config.MainConfig{BreakerFailureThreshold: 1, BreakerHalfOpenRequests: 1,
+		BreakerOpenTimeout: time.Hour, BackendTimeout: time.Second})
+	intercept := b.UnaryClientInterceptor()
+	invoked := 0
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		invoked++
+		return status.Error(codes.Unavailable, "down")
+	}
+
+	_ = intercept(context.Background(), "/user.UserService/UserById", nil, nil, nil, invoker)
+	if b.Breaker().State() != StateOpen {
+		t.Fatalf("expected breaker to open, got %s", b.Breaker().State())
+	}
+	_ = intercept(context.Background(), "/user.UserService/UserById", nil, nil, nil, invoker)
+	_ = intercept(context.Background(), "/grpc.health.v1.Health/Check", nil, nil, nil, invoker)
+	if invoked != 2 {
+		t.Errorf("expected only health check to reach backend through open breaker, got %d calls", invoked)
+	}
+}
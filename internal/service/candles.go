@@ -0,0 +1,156 @@
+// Package service price candles
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CandleRepository candle aggregator of price stream
+//
+//go:generate mockery --name=CandleRepository --case=underscore --output=./mocks
+type CandleRepository interface {
+	HasInterval(interval string) bool
+	Add(prices []*model.Price, at time.Time) []*model.Candle
+	Get(name, interval string, limit int) ([]*model.Candle, error)
+}
+
+// GetCandles last limit candles of symbol at interval, oldest first
+func (p *Price) GetCandles(_ context.Context, name, interval string, limit int) ([]*model.Candle, error) {
+	candles, err := p.candleRepository.Get(name, interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("price - GetCandles - Get: %w", err)
+	}
+	return candles, nil
+}
+
+// AddCandleSubscription add names to candle subscription at interval, returns resulting names
+func (p *Price) AddCandleSubscription(ctx context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error) {
+	return p.UpdateCandleSubscription(ctx, socketID, interval, append(p.subscribedCandles(socketID, interval), names...))
+}
+
+// RemoveCandleSubscription remove names from candle subscription at interval, returns resulting names
+func (p *Price) RemoveCandleSubscription(ctx context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error) {
+	return p.UpdateCandleSubscription(ctx, socketID, interval, difference(p.subscribedCandles(socketID, interval), names))
+}
+
+// GetCandleSubscription names subscribed for candles at interval
+func (p *Price) GetCandleSubscription(socketID uuid.UUID, interval string) ([]string, error) {
+	if !p.candleRepository.HasInterval(interval) {
+		return nil, fmt.Errorf("price - GetCandleSubscription: %w", model.ErrUnknownInterval)
+	}
+	if _, ok := p.sMap.Load(socketID); !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	names := p.subscribedCandles(socketID, interval)
+	sort.Strings(names)
+	return names, nil
+}
+
+// UpdateCandleSubscription replace names subscribed for candles at interval, the current candle of every newly added name
+// is sent right away, returns resulting names
+func (p *Price) UpdateCandleSubscription(_ context.Context, socketID uuid.UUID, interval string, names []string) ([]string, error) {
+	if !p.candleRepository.HasInterval(interval) {
+		return nil, fmt.Errorf("price - UpdateCandleSubscription: %w", model.ErrUnknownInterval)
+	}
+	value, ok := p.sMap.Load(socketID)
+	if !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	subscriber := value.(*repository.Subscriber)
+	names = difference(names, nil)
+	added := difference(names, p.subscribedCandles(socketID, interval))
+
+	p.candleMU.Lock()
+	symbols, ok := p.candleSubs[interval]
+	if !ok {
+		symbols = make(map[string]map[uuid.UUID]*repository.Subscriber)
+		p.candleSubs[interval] = symbols
+	}
+	for name, subs := range symbols {
+		delete(subs, socketID)
+		if len(subs) == 0 {
+			delete(symbols, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := symbols[name]; !ok {
+			symbols[name] = make(map[uuid.UUID]*repository.Subscriber)
+		}
+		symbols[name][socketID] = subscriber
+	}
+	p.candleMU.Unlock()
+
+	for _, name := range added {
+		current, err := p.candleRepository.Get(name, interval, 1)
+		if err == nil && len(current) > 0 {
+			subscriber.Send(&model.PriceMessage{Type: model.FrameCandle, Candle: current[0]})
+		}
+	}
+
+	err := p.syncStream(false)
+	if err != nil {
+		logrus.Warnf("price - UpdateCandleSubscription - syncStream: %v", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// subscribedCandles names subscribed by socket for candles at interval
+func (p *Price) subscribedCandles(socketID uuid.UUID, interval string) []string {
+	p.candleMU.RLock()
+	defer p.candleMU.RUnlock()
+	var names []string
+	for name, subs := range p.candleSubs[interval] {
+		if _, ok := subs[socketID]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// candleNames names subscribed for candles at any interval
+func (p *Price) candleNames() []string {
+	p.candleMU.RLock()
+	defer p.candleMU.RUnlock()
+	var names []string
+	for _, symbols := range p.candleSubs {
+		for name := range symbols {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// deleteCandleSubscriber remove socket from all candle subscriptions
+func (p *Price) deleteCandleSubscriber(socketID uuid.UUID) {
+	p.candleMU.Lock()
+	defer p.candleMU.Unlock()
+	for _, symbols := range p.candleSubs {
+		for name, subs := range symbols {
+			delete(subs, socketID)
+			if len(subs) == 0 {
+				delete(symbols, name)
+			}
+		}
+	}
+}
+
+// publishCandles send updated candles to their subscribers
+func (p *Price) publishCandles(candles []*model.Candle) {
+	p.candleMU.RLock()
+	defer p.candleMU.RUnlock()
+	for _, c := range candles {
+		for _, sub := range p.candleSubs[c.Interval][c.Name] {
+			sub.Send(&model.PriceMessage{Type: model.FrameCandle, Candle: c})
+		}
+	}
+}
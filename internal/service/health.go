@@ -0,0 +1,78 @@
+// Package service health service
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// priceStreamName name of price stream dependency in health report
+const priceStreamName = "price-stream"
+
+// HealthRepository repository interface for backend health check
+//
+//go:generate mockery --name=HealthRepository --case=underscore --output=./mocks
+type HealthRepository interface {
+	Check(ctx context.Context) *model.DependencyStatus
+}
+
+// StreamRepository repository interface for price stream state
+//
+//go:generate mockery --name=StreamRepository --case=underscore --output=./mocks
+type StreamRepository interface {
+	Alive() bool
+}
+
+// Health service
+type Health struct {
+	backends []HealthRepository
+	stream   StreamRepository
+	timeout  time.Duration
+}
+
+// NewHealthService new health service
+func NewHealthService(stream StreamRepository, timeout time.Duration, backends ...HealthRepository) *Health {
+	return &Health{backends: backends, stream: stream, timeout: timeout}
+}
+
+// Liveness gateway process is running
+func (h *Health) Liveness() *model.HealthReport {
+	return &model.HealthReport{Status: model.StatusUp}
+}
+
+// Readiness check all backends in parallel, report is down if price stream or any required backend is down
+func (h *Health) Readiness(ctx context.Context) *model.HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	report := &model.HealthReport{
+		Status:       model.StatusUp,
+		Dependencies: make([]*model.DependencyStatus, len(h.backends), len(h.backends)+1),
+	}
+
+	var wg sync.WaitGroup
+	for i, b := range h.backends {
+		wg.Add(1)
+		go func(i int, b HealthRepository) {
+			defer wg.Done()
+			report.Dependencies[i] = b.Check(ctx)
+		}(i, b)
+	}
+	wg.Wait()
+
+	stream := &model.DependencyStatus{Name: priceStreamName, Status: model.StatusUp, Required: true}
+	if !h.stream.Alive() {
+		stream.Status = model.StatusDown
+	}
+	report.Dependencies = append(report.Dependencies, stream)
+
+	for _, d := range report.Dependencies {
+		if d.Required && d.Status == model.StatusDown {
+			report.Status = model.StatusDown
+		}
+	}
+	return report
+}
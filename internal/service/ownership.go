@@ -0,0 +1,47 @@
+// Package service ownership service
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// Ownership service checking that resources belong to user
+type Ownership struct {
+	tradingRepository TradingRepository
+	accountRepository AccountRepository
+}
+
+// NewOwnershipService new ownership service
+func NewOwnershipService(tr TradingRepository, ar AccountRepository) *Ownership {
+	return &Ownership{tradingRepository: tr, accountRepository: ar}
+}
+
+// CheckPosition check that position belongs to user,
+// trading service doesn't return position owner, so position is searched among user positions
+func (o *Ownership) CheckPosition(ctx context.Context, userID, positionID string) error {
+	positions, err := o.tradingRepository.GetUserPositions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ownership - CheckPosition - GetUserPositions: %w", err)
+	}
+	for _, p := range positions {
+		if p.ID == positionID {
+			return nil
+		}
+	}
+	return fmt.Errorf("ownership - CheckPosition: %w", model.ErrNotOwned)
+}
+
+// CheckAccount check that account belongs to user
+func (o *Ownership) CheckAccount(ctx context.Context, userID, accountID string) error {
+	account, err := o.accountRepository.GetAccount(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ownership - CheckAccount - GetAccount: %w", err)
+	}
+	if account.ID != accountID {
+		return fmt.Errorf("ownership - CheckAccount: %w", model.ErrNotOwned)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package service portfolio service
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Portfolio service
+type Portfolio struct {
+	accountRepository AccountRepository
+	tradingRepository TradingRepository
+	priceService      MarketPriceService
+}
+
+// NewPortfolioService new portfolio service
+func NewPortfolioService(ar AccountRepository, tr TradingRepository, ps MarketPriceService) *Portfolio {
+	return &Portfolio{accountRepository: ar, tradingRepository: tr, priceService: ps}
+}
+
+// GetPortfolio summary of user account and positions, account and positions are requested in parallel,
+// then prices of open positions, every failed section is reported in errors instead of failing the whole summary
+func (p *Portfolio) GetPortfolio(ctx context.Context, userID string) *model.Portfolio {
+	portfolio := &model.Portfolio{Errors: make(map[string]error)}
+	var positions []*model.Position
+	var accountErr, positionsErr error
+
+	// errors are reported per section, so group never fails and doesn't cancel other requests
+	var g errgroup.Group
+	g.Go(func() error {
+		portfolio.Account, accountErr = p.accountRepository.GetAccount(ctx, userID)
+		return nil
+	})
+	g.Go(func() error {
+		positions, positionsErr = p.tradingRepository.GetUserPositions(ctx, userID)
+		return nil
+	})
+	_ = g.Wait()
+
+	if accountErr != nil {
+		portfolio.Account = nil
+		portfolio.Errors[model.PortfolioAccount] = fmt.Errorf("portfolio - GetPortfolio - GetAccount: %w", accountErr)
+	}
+	if positionsErr != nil {
+		portfolio.Errors[model.PortfolioPositions] = fmt.Errorf("portfolio - GetPortfolio - GetUserPositions: %w", positionsErr)
+		return portfolio
+	}
+
+	prices, err := p.prices(ctx, positions)
+	if err != nil {
+		portfolio.Errors[model.PortfolioPrices] = err
+	}
+	portfolio.Positions = summarize(positions, prices)
+	if portfolio.Account != nil && portfolio.Positions.UnrealizedPnL != nil {
+		equity := portfolio.Account.Amount + *portfolio.Positions.UnrealizedPnL
+		portfolio.Equity = &equity
+	}
+	return portfolio
+}
+
+// prices current prices of open positions, nil if some of them are unavailable
+func (p *Portfolio) prices(ctx context.Context, positions []*model.Position) (map[string]*model.CurrentPrice, error) {
+	var names []string
+	for _, position := range positions {
+		if position.Open() {
+			names = append(names, position.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	names = difference(names, nil)
+
+	prices, err := p.priceService.GetCurrentPrices(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("portfolio - prices - GetCurrentPrices: %w", err)
+	}
+	missing := difference(names, keys(prices))
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("portfolio - prices: %w: %v", model.ErrPriceUnavailable, missing)
+	}
+	return prices, nil
+}
+
+// summarize counts, pnl and exposure of positions, unrealized pnl and values are set only with prices of open positions
+func summarize(positions []*model.Position, prices map[string]*model.CurrentPrice) *model.PositionsSummary {
+	summary := &model.PositionsSummary{Exposure: []*model.Exposure{}}
+	exposure := make(map[string]*model.Exposure)
+	var unrealized float64
+	for _, position := range positions {
+		if !position.Open() {
+			summary.Closed++
+			summary.RealizedPnL += position.RealizedPnL()
+			continue
+		}
+		summary.Open++
+
+		e, ok := exposure[position.Name]
+		if !ok {
+			e = &model.Exposure{Name: position.Name}
+			exposure[position.Name] = e
+			summary.Exposure = append(summary.Exposure, e)
+		}
+		if position.ShortPosition {
+			e.Short += position.Amount
+		} else {
+			e.Long += position.Amount
+		}
+		e.Net = e.Long - e.Short
+
+		if price, ok := prices[position.Name]; ok {
+			pnl := model.NewPnL(position, price.Price)
+			unrealized += pnl.Absolute
+			value := pnl.MarketPrice * position.Amount
+			if e.Value != nil {
+				value += *e.Value
+			}
+			e.Value = &value
+		}
+	}
+
+	if prices != nil || summary.Open == 0 {
+		summary.UnrealizedPnL = &unrealized
+	}
+	sort.Slice(summary.Exposure, func(i, j int) bool { return summary.Exposure[i].Name < summary.Exposure[j].Name })
+	return summary
+}
@@ -4,9 +4,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -15,6 +18,9 @@ import (
 // bufferSize number of messages stored for every grpc stream
 const bufferSize = 1000
 
+// gapFrames messages sent for resumed symbol that isn't replayed, gap and snapshot
+const gapFrames = 2
+
 // PriceRepository repository interface for price service
 //
 //go:generate mockery --name=PriceRepository --case=underscore --output=./mocks
@@ -23,6 +29,8 @@ type PriceRepository interface {
 
 	GetPrices() ([]*model.Price, error)
 	UpdateSubscription(names []string) error
+	Reconnect(ctx context.Context) error
+	ResetBackoff()
 }
 
 // ListenersRepository repository of channels from websocket to stream
@@ -30,29 +38,121 @@ type PriceRepository interface {
 //go:generate mockery --name=ListenersRepository --case=underscore --output=./mocks
 type ListenersRepository interface {
 	GetPrices() []string
-	Send(prices []*model.Price)
-	Update(streamID uuid.UUID, streamChan chan *model.Price, prices []string)
+	Names(streamID uuid.UUID) []string
+	Send(ticks []*model.PriceTick)
+	Update(streamID uuid.UUID, subscriber *repository.Subscriber, prices []string)
 	Delete(streamID uuid.UUID)
 }
 
+// PriceCacheRepository last-value cache of prices from stream
+//
+//go:generate mockery --name=PriceCacheRepository --case=underscore --output=./mocks
+type PriceCacheRepository interface {
+	Set(ticks []*model.PriceTick)
+	Get(names []string) map[string]*model.CachedPrice
+}
+
+// PriceHistoryRepository recent ticks of price stream
+//
+//go:generate mockery --name=PriceHistoryRepository --case=underscore --output=./mocks
+type PriceHistoryRepository interface {
+	Add(prices []*model.Price, at time.Time) []*model.PriceTick
+	Get(name string, from, to time.Time) []*model.PriceTick
+	Since(name string, seq uint64) ([]*model.PriceTick, bool)
+	Latest(name string) uint64
+}
+
+// PriceConfig price service settings
+type PriceConfig struct {
+	// SlowConsumerPolicy is applied to subscribers with full buffer
+	SlowConsumerPolicy string
+	// CacheMaxAge cached prices older than it are stale and requested from price service
+	CacheMaxAge time.Duration
+	// PinnedNames are streamed from price service even without subscribers, e.g. to build their candles
+	PinnedNames []string
+	// MaxReplay resumed subscription missing more ticks of symbol gets gap and snapshot instead of replay
+	MaxReplay int
+}
+
 // Price service
 type Price struct {
-	priceRepository PriceRepository
+	priceRepository   PriceRepository
+	priceCache        PriceCacheRepository
+	candleRepository  CandleRepository
+	historyRepository PriceHistoryRepository
 
 	lisRepos ListenersRepository
 	sMap     sync.Map
+	// feedMU orders snapshots of new subscriptions with updates from stream
+	feedMU sync.Mutex
+
+	// streamNames names last sent to price service, streamSynced is false if sending failed
+	streamMU     sync.Mutex
+	streamNames  []string
+	streamSynced bool
+
+	// candleSubs candle subscribers by interval and symbol
+	candleMU   sync.RWMutex
+	candleSubs map[string]map[string]map[uuid.UUID]*repository.Subscriber
+
+	cfg PriceConfig
 }
 
 // NewPriceService new price service
-func NewPriceService(ctx context.Context, rps PriceRepository, lr ListenersRepository) *Price {
-	price := &Price{priceRepository: rps, lisRepos: lr}
+func NewPriceService(ctx context.Context, rps PriceRepository, pc PriceCacheRepository, cr CandleRepository,
+	hr PriceHistoryRepository, lr ListenersRepository, cfg PriceConfig) (*Price, error) {
+	if !model.ValidSlowConsumerPolicy(cfg.SlowConsumerPolicy) {
+		return nil, fmt.Errorf("price - NewPriceService: unknown slow consumer policy %q", cfg.SlowConsumerPolicy)
+	}
+	price := &Price{
+		priceRepository:   rps,
+		priceCache:        pc,
+		candleRepository:  cr,
+		historyRepository: hr,
+		lisRepos:          lr,
+		candleSubs:        make(map[string]map[string]map[uuid.UUID]*repository.Subscriber),
+		cfg:               cfg,
+	}
 	go price.cycle(ctx)
-	return price
+	return price, nil
+}
+
+// GetCurrentPrices get current prices from cache, only stale and missing prices are requested from price service
+func (p *Price) GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.CurrentPrice, error) {
+	now := time.Now()
+	current := make(map[string]*model.CurrentPrice, len(names))
+	for name, cached := range p.fresh(names) {
+		current[name] = &model.CurrentPrice{Price: cached.Price, Cached: true, AgeMS: now.Sub(cached.Received).Milliseconds()}
+	}
+
+	missing := difference(names, keys(current))
+	if len(missing) == 0 {
+		return current, nil
+	}
+	prices, err := p.priceRepository.GetCurrentPrices(ctx, missing)
+	if err != nil {
+		return nil, fmt.Errorf("price - GetCurrentPrices - GetCurrentPrices: %w", err)
+	}
+	for name, price := range prices {
+		current[name] = &model.CurrentPrice{Price: price}
+	}
+	return current, nil
+}
+
+// fresh cached prices younger than cacheMaxAge
+func (p *Price) fresh(names []string) map[string]*model.CachedPrice {
+	cached := p.priceCache.Get(names)
+	for name, c := range cached {
+		if time.Since(c.Received) > p.cfg.CacheMaxAge {
+			delete(cached, name)
+		}
+	}
+	return cached
 }
 
-// GetCurrentPrices get current prices from price service
-func (p *Price) GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.Price, error) {
-	return p.priceRepository.GetCurrentPrices(ctx, names)
+// GetHistory ticks of symbol received in [from, to] from the oldest to the newest
+func (p *Price) GetHistory(_ context.Context, name string, from, to time.Time) []*model.PriceTick {
+	return p.historyRepository.Get(name, from, to)
 }
 
 // GetPrices get price update from price service
@@ -60,40 +160,210 @@ func (p *Price) GetPrices() ([]*model.Price, error) {
 	return p.priceRepository.GetPrices()
 }
 
-// Subscribe allocating new channel for grpc stream with id and returning it
-func (p *Price) Subscribe(streamID uuid.UUID) chan *model.Price {
-	streamChan := make(chan *model.Price, bufferSize)
-	p.sMap.Store(streamID, streamChan)
-	return streamChan
+// Subscribe allocating new subscriber for grpc stream with id and returning it
+func (p *Price) Subscribe(streamID uuid.UUID) *repository.Subscriber {
+	subscriber := repository.NewSubscriber(bufferSize, p.cfg.SlowConsumerPolicy)
+	p.sMap.Store(streamID, subscriber)
+	return subscriber
 }
 
-// UpdateSubscription update list of price for subscriptions
-func (p *Price) UpdateSubscription(socketID uuid.UUID, names []string) error {
-	streamChan, ok := p.sMap.Load(socketID)
+// AddSubscription add names to subscription, returns resulting names
+func (p *Price) AddSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error) {
+	return p.UpdateSubscription(ctx, socketID, append(p.lisRepos.Names(socketID), names...))
+}
+
+// RemoveSubscription remove names from subscription, returns resulting names
+func (p *Price) RemoveSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error) {
+	return p.UpdateSubscription(ctx, socketID, difference(p.lisRepos.Names(socketID), names))
+}
+
+// GetSubscription subscribed names
+func (p *Price) GetSubscription(socketID uuid.UUID) ([]string, error) {
+	if _, ok := p.sMap.Load(socketID); !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	names := p.lisRepos.Names(socketID)
+	sort.Strings(names)
+	return names, nil
+}
+
+// UpdateSubscription replace subscribed names, newly added prices are sent as snapshot before updates,
+// returns resulting names
+func (p *Price) UpdateSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error) {
+	value, ok := p.sMap.Load(socketID)
 	if !ok {
-		return fmt.Errorf("not found")
+		return nil, fmt.Errorf("not found")
 	}
+	subscriber := value.(*repository.Subscriber)
+	names = difference(names, nil)
+	added := difference(names, p.lisRepos.Names(socketID))
+	fetched := p.fetchUncached(ctx, added)
+
+	p.feedMU.Lock()
+	// slow subscriber is handled by its policy, updates of added names follow snapshots anyway
+	_ = p.sendSnapshots(subscriber, added, fetched)
 	p.lisRepos.Delete(socketID)
-	p.lisRepos.Update(socketID, streamChan.(chan *model.Price), names)
-	err := p.priceRepository.UpdateSubscription(p.lisRepos.GetPrices())
+	p.lisRepos.Update(socketID, subscriber, names)
+	p.feedMU.Unlock()
+
+	err := p.syncStream(false)
+	if err != nil {
+		// stream is broken, cycle will reconnect and replay all names including these
+		logrus.Warnf("price - UpdateSubscription - syncStream: %v", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ResumeSubscription add names of last seen sequence numbers to subscription and replay ticks missed since them,
+// if missed ticks can't be replayed or don't fit into subscriber buffer gap and snapshot are sent instead,
+// returns resulting names or ErrResumeFailed if subscriber was slow anyway, subscription isn't changed then
+func (p *Price) ResumeSubscription(ctx context.Context, socketID uuid.UUID, last map[string]uint64) ([]string, error) {
+	value, ok := p.sMap.Load(socketID)
+	if !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	subscriber := value.(*repository.Subscriber)
+	resumed := keys(last)
+	sort.Strings(resumed)
+	names := difference(append(p.lisRepos.Names(socketID), resumed...), nil)
+	fetched := p.fetchUncached(ctx, resumed)
+
+	p.feedMU.Lock()
+	ok = p.replay(subscriber, resumed, last, fetched)
+	if ok {
+		p.lisRepos.Delete(socketID)
+		p.lisRepos.Update(socketID, subscriber, names)
+	}
+	p.feedMU.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("price - ResumeSubscription - replay: %w", model.ErrResumeFailed)
+	}
+
+	err := p.syncStream(false)
 	if err != nil {
-		return fmt.Errorf("price - UpdateSubscription - UpdateSubscription: %w", err)
+		logrus.Warnf("price - ResumeSubscription - syncStream: %v", err)
 	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// replay send ticks of resumed names missed since last seen sequence numbers while they fit into free buffer,
+// every name keeps room for its gap and snapshot, which are sent instead of ticks that can't be replayed,
+// false if subscriber was slow, must be called under feedMU
+func (p *Price) replay(subscriber *repository.Subscriber, resumed []string, last map[string]uint64, fetched map[string]*model.Price) bool {
+	budget := subscriber.Free() - gapFrames*len(resumed)
+	var gaps []string
+	for _, name := range resumed {
+		ticks, ok := p.historyRepository.Since(name, last[name])
+		if ok && len(ticks) <= p.cfg.MaxReplay && len(ticks) <= budget+gapFrames {
+			budget -= len(ticks) - gapFrames
+			for _, t := range ticks {
+				if !subscriber.Send(&model.PriceMessage{Type: model.FrameUpdate, Price: t.Price, Seq: t.Seq, Time: t.Time}) {
+					return false
+				}
+			}
+			continue
+		}
+		gaps = append(gaps, name)
+		gap := &model.Gap{Name: name, From: last[name] + 1, To: p.historyRepository.Latest(name)}
+		if !subscriber.Send(&model.PriceMessage{Type: model.FrameGap, Gap: gap}) {
+			return false
+		}
+	}
+	return p.sendSnapshots(subscriber, gaps, fetched)
+}
+
+// sendSnapshots send cached prices of names, or fetched ones if cache is stale, false if subscriber was slow,
+// must be called under feedMU
+func (p *Price) sendSnapshots(subscriber *repository.Subscriber, names []string, fetched map[string]*model.Price) bool {
+	snapshot := p.fresh(names)
+	for _, name := range names {
+		var msg *model.PriceMessage
+		if cached, ok := snapshot[name]; ok {
+			msg = &model.PriceMessage{Type: model.FrameSnapshot, Price: cached.Price, Seq: cached.Seq, Time: cached.Received}
+		} else if price, ok := fetched[name]; ok {
+			msg = &model.PriceMessage{Type: model.FrameSnapshot, Price: price, Time: time.Now()}
+		} else {
+			continue
+		}
+		if !subscriber.Send(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// syncStream send subscribed names to price service if they differ from the last sent ones or force is set
+func (p *Price) syncStream(force bool) error {
+	p.streamMU.Lock()
+	defer p.streamMU.Unlock()
+
+	names := difference(append(append(p.lisRepos.GetPrices(), p.candleNames()...), p.cfg.PinnedNames...), nil)
+	sort.Strings(names)
+	if !force && p.streamSynced && equal(names, p.streamNames) {
+		return nil
+	}
+	err := p.priceRepository.UpdateSubscription(names)
+	if err != nil {
+		p.streamSynced = false
+		return fmt.Errorf("price - syncStream - UpdateSubscription: %w", err)
+	}
+	p.streamNames, p.streamSynced = names, true
+	return nil
+}
+
+// fetchUncached get prices missing in cache or stale from price service, snapshot is best-effort so error is only logged
+func (p *Price) fetchUncached(ctx context.Context, names []string) map[string]*model.Price {
+	missing := difference(names, keys(p.fresh(names)))
+	if len(missing) == 0 {
+		return nil
+	}
+	prices, err := p.priceRepository.GetCurrentPrices(ctx, missing)
+	if err != nil {
+		logrus.Warnf("price - fetchUncached - GetCurrentPrices: %v", err)
+		return nil
+	}
+	return prices
+}
+
+// SetConflation switch subscriber to conflating mode, where only the latest price of every symbol is kept
+func (p *Price) SetConflation(socketID uuid.UUID, conflate bool) error {
+	subscriber, ok := p.sMap.Load(socketID)
+	if !ok {
+		return fmt.Errorf("not found")
+	}
+	subscriber.(*repository.Subscriber).SetConflating(conflate)
 	return nil
 }
 
-// DeleteSubscription delete websocket subscription and close it's chan
+// DeleteSubscription delete websocket subscription and close its subscriber
 func (p *Price) DeleteSubscription(streamID uuid.UUID) error {
-	streamChan, ok := p.sMap.Load(streamID)
+	subscriber, ok := p.sMap.LoadAndDelete(streamID)
 	if !ok {
 		return fmt.Errorf("not found")
 	}
 	p.lisRepos.Delete(streamID)
-	close(streamChan.(chan *model.Price))
-	p.sMap.Delete(streamID)
+	p.deleteCandleSubscriber(streamID)
+	subscriber.(*repository.Subscriber).Close("")
+
+	err := p.syncStream(false)
+	if err != nil {
+		logrus.Warnf("price - DeleteSubscription - syncStream: %v", err)
+	}
 	return nil
 }
 
+// BufferFill fill ratio of every subscriber buffer
+func (p *Price) BufferFill() []float64 {
+	var fill []float64
+	p.sMap.Range(func(_, value interface{}) bool {
+		fill = append(fill, value.(*repository.Subscriber).Fill())
+		return true
+	})
+	return fill
+}
+
 // cycle getting data from price service and sending to websocket subscribers
 func (p *Price) cycle(ctx context.Context) {
 	var prices []*model.Price
@@ -106,10 +376,92 @@ func (p *Price) cycle(ctx context.Context) {
 		default:
 			prices, err = p.priceRepository.GetPrices()
 			if err != nil {
-				logrus.Fatalf("prices - cycle - GetPrices: %v", err)
-				return
+				logrus.Errorf("prices - cycle - GetPrices: %v", err)
+				p.broadcastStatus(model.FeedStale, "price stream is unavailable, reconnecting")
+				err = p.resubscribe(ctx)
+				if err != nil {
+					logrus.Errorf("prices - cycle - resubscribe: %v", err)
+					return
+				}
+				p.broadcastStatus(model.FeedRecovered, "")
+				continue
 			}
-			p.lisRepos.Send(prices)
+			now := time.Now()
+			p.feedMU.Lock()
+			ticks := p.historyRepository.Add(prices, now)
+			p.priceCache.Set(ticks)
+			p.lisRepos.Send(ticks)
+			p.feedMU.Unlock()
+			p.publishCandles(p.candleRepository.Add(prices, now))
+		}
+	}
+}
+
+// resubscribe reopen price stream and replay current subscriptions, stream that can't be synced is reopened
+// after backoff, which is reset only once sync succeeds
+func (p *Price) resubscribe(ctx context.Context) error {
+	for {
+		err := p.priceRepository.Reconnect(ctx)
+		if err != nil {
+			return fmt.Errorf("price - resubscribe - Reconnect: %w", err)
+		}
+
+		err = p.syncStream(true)
+		if err == nil {
+			p.priceRepository.ResetBackoff()
+			return nil
+		}
+		logrus.Errorf("price - resubscribe - syncStream: %v", err)
+	}
+}
+
+// broadcastStatus send feed status to every subscriber
+func (p *Price) broadcastStatus(status, reason string) {
+	msg := &model.PriceMessage{Type: model.FrameStatus, Status: &model.FeedStatus{
+		Status: status,
+		Reason: reason,
+		Time:   time.Now().UTC(),
+	}}
+
+	p.sMap.Range(func(_, value interface{}) bool {
+		value.(*repository.Subscriber).Send(msg)
+		return true
+	})
+}
+
+// difference distinct names from a missing in b
+func difference(a, b []string) []string {
+	set := make(map[string]struct{}, len(b))
+	for _, name := range b {
+		set[name] = struct{}{}
+	}
+	var diff []string
+	for _, name := range a {
+		if _, ok := set[name]; !ok {
+			set[name] = struct{}{}
+			diff = append(diff, name)
+		}
+	}
+	return diff
+}
+
+func keys[V any](prices map[string]V) []string {
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	return names
+}
+
+// equal sorted names are the same
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
 }
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
+)
+
+// fakeHistory history of gold and oil with ticks 1..n
+type fakeHistory map[string]int
+
+func (f fakeHistory) Add([]*model.Price, time.Time) []*model.PriceTick { return nil }
+
+func (f fakeHistory) Get(string, time.Time, time.Time) []*model.PriceTick { return nil }
+
+func (f fakeHistory) Since(name string, seq uint64) ([]*model.PriceTick, bool) {
+	var ticks []*model.PriceTick
+	for s := seq + 1; s <= uint64(f[name]); s++ {
+		ticks = append(ticks, &model.PriceTick{Price: &model.Price{Name: name}, Seq: s})
+	}
+	return ticks, true
+}
+
+func (f fakeHistory) Latest(name string) uint64 { return uint64(f[name]) }
+
+// fakeCache cache with a fresh price of every name
+type fakeCache struct{}
+
+func (fakeCache) Set([]*model.PriceTick) {}
+
+func (fakeCache) Get(names []string) map[string]*model.CachedPrice {
+	cached := make(map[string]*model.CachedPrice, len(names))
+	for _, name := range names {
+		cached[name] = &model.CachedPrice{Price: &model.Price{Name: name}, Received: time.Now()}
+	}
+	return cached
+}
+
+func TestReplayFitsFreeBuffer(t *testing.T) {
+	p := &Price{historyRepository: fakeHistory{"gold": 4, "oil": 7}, priceCache: fakeCache{}, cfg: PriceConfig{MaxReplay: 100, CacheMaxAge: time.Minute}}
+	subscriber := repository.NewSubscriber(10, model.SlowConsumerDisconnect)
+
+	if !p.replay(subscriber, []string{"gold", "oil"}, map[string]uint64{"gold": 0, "oil": 0}, nil) {
+		t.Fatal("expected replay to fit into buffer")
+	}
+	var types []string
+	for _, msg := range subscriber.DrainChanged() {
+		types = append(types, msg.Type)
+	}
+	// gold is replayed and oil doesn't fit into what is left after room for its gap and snapshot
+	want := []string{model.FrameUpdate, model.FrameUpdate, model.FrameUpdate, model.FrameUpdate, model.FrameGap, model.FrameSnapshot}
+	if len(types) != len(want) {
+		t.Fatalf("expected %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, types)
+		}
+	}
+}
+
+func TestReplayFailsForSlowSubscriber(t *testing.T) {
+	p := &Price{historyRepository: fakeHistory{"gold": 4}, priceCache: fakeCache{}, cfg: PriceConfig{MaxReplay: 100, CacheMaxAge: time.Minute}}
+	subscriber := repository.NewSubscriber(1, model.SlowConsumerDisconnect)
+	subscriber.Send(&model.PriceMessage{Type: model.FrameStatus, Status: &model.FeedStatus{}})
+
+	if p.replay(subscriber, []string{"gold"}, map[string]uint64{"gold": 0}, nil) {
+		t.Error("expected replay into full buffer to fail")
+	}
+}
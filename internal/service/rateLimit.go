@@ -0,0 +1,71 @@
+// Package service rate limit service
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// defaultRatePolicy key of policy for routes without own policy
+const defaultRatePolicy = "default"
+
+// RateLimitRepository token buckets store, shared stores must implement it to replace in-memory one
+//
+//go:generate mockery --name=RateLimitRepository --case=underscore --output=./mocks
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, policy model.RatePolicy) (*model.RateDecision, error)
+}
+
+// RateLimit service
+type RateLimit struct {
+	rateLimitRepository RateLimitRepository
+
+	policies map[string]model.RatePolicy
+}
+
+// NewRateLimitService new rate limit service, policies are "limit/period" per route, e.g. "/auth/login": "5/1m",
+// policy with "default" key is used for routes without own policy
+func NewRateLimitService(rps RateLimitRepository, policies map[string]string) (*RateLimit, error) {
+	parsed := make(map[string]model.RatePolicy, len(policies))
+	for route, p := range policies {
+		policy, err := parseRatePolicy(p)
+		if err != nil {
+			return nil, fmt.Errorf("rateLimit - NewRateLimitService - parseRatePolicy: %w", err)
+		}
+		parsed[route] = policy
+	}
+	if _, ok := parsed[defaultRatePolicy]; !ok {
+		return nil, fmt.Errorf("rateLimit - NewRateLimitService: %q policy is required", defaultRatePolicy)
+	}
+	return &RateLimit{rateLimitRepository: rps, policies: parsed}, nil
+}
+
+// Allow take token for key from bucket of route
+func (r *RateLimit) Allow(ctx context.Context, route, key string) (*model.RateDecision, error) {
+	policy, ok := r.policies[route]
+	if !ok {
+		policy = r.policies[defaultRatePolicy]
+	}
+	return r.rateLimitRepository.Take(ctx, route+"|"+key, policy)
+}
+
+func parseRatePolicy(policy string) (model.RatePolicy, error) {
+	limit, period, ok := strings.Cut(policy, "/")
+	if !ok {
+		return model.RatePolicy{}, fmt.Errorf("invalid rate policy %q, expected limit/period", policy)
+	}
+	l, err := strconv.Atoi(limit)
+	if err != nil || l <= 0 {
+		return model.RatePolicy{}, fmt.Errorf("invalid rate policy limit %q", limit)
+	}
+	p, err := time.ParseDuration(period)
+	if err != nil || p <= 0 {
+		return model.RatePolicy{}, fmt.Errorf("invalid rate policy period %q", period)
+	}
+	return model.RatePolicy{Limit: l, Period: p}, nil
+}
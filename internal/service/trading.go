@@ -3,8 +3,13 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/OVantsevich/proxy-service/internal/model"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TradingRepository repository interface for trading service
@@ -19,19 +24,150 @@ type TradingRepository interface {
 	ClosePosition(ctx context.Context, positionID string) error
 }
 
+// MarketPriceService current prices for pnl of positions
+//
+//go:generate mockery --name=MarketPriceService --case=underscore --output=./mocks
+type MarketPriceService interface {
+	GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.CurrentPrice, error)
+}
+
 // Trading service
 type Trading struct {
 	tradingRepository TradingRepository
+	priceService      MarketPriceService
+
+	// minDistancePercent minimal distance of stop loss and take profit from market price in percent of it
+	minDistancePercent float64
+	// rollbackTimeout timeout of closing position which stop loss or take profit couldn't be set
+	rollbackTimeout time.Duration
 }
 
 // NewTradingService new trading service
-func NewTradingService(rps TradingRepository) *Trading {
-	return &Trading{tradingRepository: rps}
+func NewTradingService(rps TradingRepository, ps MarketPriceService, minDistancePercent float64, rollbackTimeout time.Duration) *Trading {
+	return &Trading{tradingRepository: rps, priceService: ps, minDistancePercent: minDistancePercent, rollbackTimeout: rollbackTimeout}
 }
 
-// OpenPosition open new position
+// thresholds of position
+const (
+	thresholdStopLoss   = "stop loss"
+	thresholdTakeProfit = "take profit"
+)
+
+// threshold rules, the broken one is reported in ThresholdError
+const (
+	rulePositionOpen    = "position_open"
+	ruleStopLossSide    = "stop_loss_side"
+	ruleTakeProfitSide  = "take_profit_side"
+	ruleTakeProfitEntry = "take_profit_entry"
+	ruleMinDistance     = "min_distance"
+)
+
+// percent multiplier of ratio
+const percent = 100
+
+// OpenPosition open new position with its stop loss and take profit, they are checked against current price
+// before position is opened, if they can't be set position is closed, if it can't be closed either
+// opened position is returned with ErrUnprotected. Position is closed even if ctx is done,
+// since thresholds often fail because client is gone or its deadline has passed
 func (t *Trading) OpenPosition(ctx context.Context, position *model.Position) (*model.Position, error) {
-	return t.tradingRepository.OpenPosition(ctx, position)
+	bracket := position.StopLoss != 0 || position.TakeProfit != 0
+	if bracket {
+		err := t.checkThresholds(ctx, position, position.StopLoss, position.TakeProfit)
+		if err != nil {
+			return nil, fmt.Errorf("trading - OpenPosition - checkThresholds: %w", err)
+		}
+	}
+
+	opened, err := t.tradingRepository.OpenPosition(ctx, position)
+	if err != nil || !bracket {
+		return opened, err
+	}
+
+	err = t.setThresholds(ctx, opened.ID, position.StopLoss, position.TakeProfit)
+	if err == nil {
+		opened.StopLoss, opened.TakeProfit = position.StopLoss, position.TakeProfit
+		return opened, nil
+	}
+	logrus.Errorf("trading - OpenPosition - setThresholds: %v", err)
+	// go 1.19 has no context.WithoutCancel, only span of request is kept
+	rollbackCtx, cancel := context.WithTimeout(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)), t.rollbackTimeout)
+	defer cancel()
+	closeErr := t.tradingRepository.ClosePosition(rollbackCtx, opened.ID)
+	if closeErr != nil {
+		logrus.Errorf("trading - OpenPosition - ClosePosition: position %s is unprotected: %v", opened.ID, closeErr)
+		return opened, fmt.Errorf("trading - OpenPosition: %w", model.ErrUnprotected)
+	}
+	return nil, fmt.Errorf("trading - OpenPosition: %w", model.ErrPositionClosed)
+}
+
+// checkThresholds check non-zero stop loss and take profit of position against current price:
+// position must be open, stop loss must be on losing side of market price and take profit on winning side of market
+// and entry prices, both at least min distance away from market price,
+// long position is opened at purchase price and closed at selling price, short one the other way around
+func (t *Trading) checkThresholds(ctx context.Context, position *model.Position, stopLoss, takeProfit float64) error {
+	if !position.Open() {
+		return &model.ThresholdError{Rule: rulePositionOpen, Threshold: thresholdStopLoss + " or " + thresholdTakeProfit,
+			Reason: "can't be set for closed position"}
+	}
+	prices, err := t.priceService.GetCurrentPrices(ctx, []string{position.Name})
+	if err != nil {
+		return fmt.Errorf("trading - checkThresholds - GetCurrentPrices: %w", err)
+	}
+	price, ok := prices[position.Name]
+	if !ok {
+		return fmt.Errorf("trading - checkThresholds: %w", model.ErrPriceUnavailable)
+	}
+
+	market, entry, direction, below, above := price.SellingPrice, position.PurchasePrice, 1.0, "below", "above"
+	if position.ShortPosition {
+		market, entry, direction, below, above = price.PurchasePrice, position.SellingPrice, -1.0, "above", "below"
+	}
+	minDistance := market * t.minDistancePercent / percent
+
+	if stopLoss != 0 {
+		distance := direction * (market - stopLoss)
+		if distance <= 0 {
+			return &model.ThresholdError{Rule: ruleStopLossSide, Threshold: thresholdStopLoss,
+				Reason: fmt.Sprintf("must be %s current price %v", below, market)}
+		}
+		if distance < minDistance {
+			return &model.ThresholdError{Rule: ruleMinDistance, Threshold: thresholdStopLoss,
+				Reason: fmt.Sprintf("must be at least %v away from current price %v", minDistance, market)}
+		}
+	}
+	if takeProfit != 0 {
+		if entry != 0 && direction*(takeProfit-entry) <= 0 {
+			return &model.ThresholdError{Rule: ruleTakeProfitEntry, Threshold: thresholdTakeProfit,
+				Reason: fmt.Sprintf("must be %s entry price %v", above, entry)}
+		}
+		distance := direction * (takeProfit - market)
+		if distance <= 0 {
+			return &model.ThresholdError{Rule: ruleTakeProfitSide, Threshold: thresholdTakeProfit,
+				Reason: fmt.Sprintf("must be %s current price %v", above, market)}
+		}
+		if distance < minDistance {
+			return &model.ThresholdError{Rule: ruleMinDistance, Threshold: thresholdTakeProfit,
+				Reason: fmt.Sprintf("must be at least %v away from current price %v", minDistance, market)}
+		}
+	}
+	return nil
+}
+
+// setThresholds set non-zero stop loss and take profit of position
+func (t *Trading) setThresholds(ctx context.Context, positionID string, stopLoss, takeProfit float64) error {
+	if stopLoss != 0 {
+		err := t.tradingRepository.SetStopLoss(ctx, positionID, stopLoss)
+		if err != nil {
+			return fmt.Errorf("trading - setThresholds - SetStopLoss: %w", err)
+		}
+	}
+	if takeProfit != 0 {
+		err := t.tradingRepository.SetTakeProfit(ctx, positionID, takeProfit)
+		if err != nil {
+			return fmt.Errorf("trading - setThresholds - SetTakeProfit: %w", err)
+		}
+	}
+	return nil
 }
 
 // GetPositionByID position by id
@@ -44,13 +180,76 @@ func (t *Trading) GetUserPositions(ctx context.Context, userID string) ([]*model
 	return t.tradingRepository.GetUserPositions(ctx, userID)
 }
 
-// SetStopLoss set stop loss for selected position
+// GetUserPositionsPnL get all user positions, open ones with pnl at current prices,
+// positions are returned without pnl if prices are unavailable
+func (t *Trading) GetUserPositionsPnL(ctx context.Context, userID string) ([]*model.PositionPnL, error) {
+	positions, err := t.tradingRepository.GetUserPositions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("trading - GetUserPositionsPnL - GetUserPositions: %w", err)
+	}
+
+	names := make([]string, 0, len(positions))
+	for _, p := range positions {
+		if p.Open() {
+			names = append(names, p.Name)
+		}
+	}
+	var prices map[string]*model.CurrentPrice
+	if len(names) > 0 {
+		prices, err = t.priceService.GetCurrentPrices(ctx, difference(names, nil))
+		if err != nil {
+			logrus.Warnf("trading - GetUserPositionsPnL - GetCurrentPrices: %v", err)
+		}
+	}
+
+	response := make([]*model.PositionPnL, len(positions))
+	for i, p := range positions {
+		response[i] = &model.PositionPnL{Position: p}
+		if price, ok := prices[p.Name]; ok && p.Open() {
+			response[i].PnL = model.NewPnL(p, price.Price)
+		}
+	}
+	return response, nil
+}
+
+// GetOpenPositions get open user positions
+func (t *Trading) GetOpenPositions(ctx context.Context, userID string) ([]*model.Position, error) {
+	positions, err := t.tradingRepository.GetUserPositions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("trading - GetOpenPositions - GetUserPositions: %w", err)
+	}
+	open := positions[:0]
+	for _, p := range positions {
+		if p.Open() {
+			open = append(open, p)
+		}
+	}
+	return open, nil
+}
+
+// SetStopLoss set stop loss for selected position, it's checked against position direction and current price
 func (t *Trading) SetStopLoss(ctx context.Context, positionID string, stopLoss float64) error {
+	position, err := t.tradingRepository.GetPositionByID(ctx, positionID)
+	if err != nil {
+		return fmt.Errorf("trading - SetStopLoss - GetPositionByID: %w", err)
+	}
+	err = t.checkThresholds(ctx, position, stopLoss, 0)
+	if err != nil {
+		return fmt.Errorf("trading - SetStopLoss - checkThresholds: %w", err)
+	}
 	return t.tradingRepository.SetStopLoss(ctx, positionID, stopLoss)
 }
 
-// SetTakeProfit set take profit for selected position
+// SetTakeProfit set take profit for selected position, it's checked against position direction and current price
 func (t *Trading) SetTakeProfit(ctx context.Context, positionID string, takeProfit float64) error {
+	position, err := t.tradingRepository.GetPositionByID(ctx, positionID)
+	if err != nil {
+		return fmt.Errorf("trading - SetTakeProfit - GetPositionByID: %w", err)
+	}
+	err = t.checkThresholds(ctx, position, 0, takeProfit)
+	if err != nil {
+		return fmt.Errorf("trading - SetTakeProfit - checkThresholds: %w", err)
+	}
 	return t.tradingRepository.SetTakeProfit(ctx, positionID, takeProfit)
 }
 
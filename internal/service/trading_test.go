@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+)
+
+// fakePrices market price service with fixed prices
+type fakePrices map[string]*model.CurrentPrice
+
+func (f fakePrices) GetCurrentPrices(_ context.Context, names []string) (map[string]*model.CurrentPrice, error) {
+	prices := make(map[string]*model.CurrentPrice)
+	for _, name := range names {
+		if p, ok := f[name]; ok {
+			prices[name] = p
+		}
+	}
+	return prices, nil
+}
+
+// goldPrices gold is sold at 100 and bought at 102, long positions are checked against 100 and short ones against 102
+func goldPrices() fakePrices {
+	return fakePrices{"gold": {Price: &model.Price{Name: "gold", SellingPrice: 100, PurchasePrice: 102}}}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	long := &model.Position{Name: "gold", PurchasePrice: 90}
+	short := &model.Position{Name: "gold", SellingPrice: 110, ShortPosition: true}
+	tests := []struct {
+		name       string
+		position   *model.Position
+		stopLoss   float64
+		takeProfit float64
+		rule       string
+	}{
+		{name: "long valid", position: long, stopLoss: 95, takeProfit: 105},
+		{name: "long stop loss above market", position: long, stopLoss: 101, rule: ruleStopLossSide},
+		{name: "long stop loss at market", position: long, stopLoss: 100, rule: ruleStopLossSide},
+		{name: "long stop loss too close", position: long, stopLoss: 99.95, rule: ruleMinDistance},
+		{name: "long take profit below market", position: long, takeProfit: 99, rule: ruleTakeProfitSide},
+		{name: "long take profit below entry", position: long, takeProfit: 85, rule: ruleTakeProfitEntry},
+		{name: "long take profit too close", position: long, takeProfit: 100.05, rule: ruleMinDistance},
+		{name: "short valid", position: short, stopLoss: 106, takeProfit: 98},
+		{name: "short stop loss below market", position: short, stopLoss: 101, rule: ruleStopLossSide},
+		{name: "short stop loss too close", position: short, stopLoss: 102.05, rule: ruleMinDistance},
+		{name: "short take profit above market", position: short, takeProfit: 103, rule: ruleTakeProfitSide},
+		{name: "short take profit above entry", position: short, takeProfit: 115, rule: ruleTakeProfitEntry},
+		{name: "short take profit too close", position: short, takeProfit: 101.95, rule: ruleMinDistance},
+		{name: "closed position", position: &model.Position{Name: "gold", Closed: 1}, stopLoss: 95, rule: rulePositionOpen},
+	}
+	trading := NewTradingService(nil, goldPrices(), 0.1, time.Second)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := trading.checkThresholds(context.Background(), tt.position, tt.stopLoss, tt.takeProfit)
+			if tt.rule == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			var thresholdErr *model.ThresholdError
+			if !errors.As(err, &thresholdErr) || thresholdErr.Rule != tt.rule {
+				t.Errorf("expected %s rule to be broken, got %v", tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestCheckThresholdsPriceUnavailable(t *testing.T) {
+	trading := NewTradingService(nil, fakePrices{}, 0.1, time.Second)
+	err := trading.checkThresholds(context.Background(), &model.Position{Name: "gold"}, 95, 0)
+	if !errors.Is(err, model.ErrPriceUnavailable) {
+		t.Errorf("expected ErrPriceUnavailable, got %v", err)
+	}
+}
+
+// fakeTrading trading repository opening position "1", setting thresholds and closing fail with configured errors
+// or when ctx is done
+type fakeTrading struct {
+	stopLossErr, closeErr error
+	stopLoss, takeProfit  float64
+	closed                bool
+}
+
+func (f *fakeTrading) OpenPosition(_ context.Context, position *model.Position) (*model.Position, error) {
+	opened := *position
+	opened.ID, opened.StopLoss, opened.TakeProfit = "1", 0, 0
+	return &opened, nil
+}
+
+func (f *fakeTrading) GetPositionByID(context.Context, string) (*model.Position, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeTrading) GetUserPositions(context.Context, string) ([]*model.Position, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeTrading) SetStopLoss(ctx context.Context, _ string, stopLoss float64) error {
+	if f.stopLossErr != nil {
+		return f.stopLossErr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	f.stopLoss = stopLoss
+	return nil
+}
+
+func (f *fakeTrading) SetTakeProfit(_ context.Context, _ string, takeProfit float64) error {
+	f.takeProfit = takeProfit
+	return nil
+}
+
+func (f *fakeTrading) ClosePosition(ctx context.Context, _ string) error {
+	if f.closeErr != nil {
+		return f.closeErr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	f.closed = true
+	return nil
+}
+
+func TestOpenPositionBracket(t *testing.T) {
+	backendErr := errors.New("backend is unavailable")
+	tests := []struct {
+		name       string
+		repository *fakeTrading
+		err        error
+		opened     bool
+		closed     bool
+	}{
+		{name: "thresholds set", repository: &fakeTrading{}, opened: true},
+		{name: "rolled back", repository: &fakeTrading{stopLossErr: backendErr}, err: model.ErrPositionClosed, closed: true},
+		{name: "unprotected", repository: &fakeTrading{stopLossErr: backendErr, closeErr: backendErr}, err: model.ErrUnprotected, opened: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trading := NewTradingService(tt.repository, goldPrices(), 0.1, time.Second)
+			position, err := trading.OpenPosition(context.Background(),
+				&model.Position{Name: "gold", Amount: 1, PurchasePrice: 90, StopLoss: 95, TakeProfit: 105})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+			if (position != nil) != tt.opened {
+				t.Errorf("expected opened position %v, got %v", tt.opened, position)
+			}
+			if tt.repository.closed != tt.closed {
+				t.Errorf("expected closed %v, got %v", tt.closed, tt.repository.closed)
+			}
+			if tt.err == nil && (position.StopLoss != 95 || position.TakeProfit != 105 ||
+				tt.repository.stopLoss != 95 || tt.repository.takeProfit != 105) {
+				t.Errorf("expected thresholds to be set, got %+v", position)
+			}
+		})
+	}
+}
+
+func TestOpenPositionBracketRejected(t *testing.T) {
+	repository := &fakeTrading{}
+	trading := NewTradingService(repository, goldPrices(), 0.1, time.Second)
+	_, err := trading.OpenPosition(context.Background(), &model.Position{Name: "gold", Amount: 1, StopLoss: 101})
+
+	var thresholdErr *model.ThresholdError
+	if !errors.As(err, &thresholdErr) || thresholdErr.Rule != ruleStopLossSide {
+		t.Errorf("expected stop loss side to be rejected before opening, got %v", err)
+	}
+	if repository.stopLoss != 0 || repository.closed {
+		t.Error("expected position not to be opened")
+	}
+}
+
+func TestOpenPositionBracketClientGone(t *testing.T) {
+	repository := &fakeTrading{}
+	trading := NewTradingService(repository, goldPrices(), 0.1, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := trading.OpenPosition(ctx, &model.Position{Name: "gold", Amount: 1, PurchasePrice: 90, StopLoss: 95})
+	if !errors.Is(err, model.ErrPositionClosed) || !repository.closed {
+		t.Errorf("expected position to be closed after client is gone, got %v", err)
+	}
+}
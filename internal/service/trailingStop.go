@@ -0,0 +1,267 @@
+// Package service trailing stop service
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/OVantsevich/proxy-service/internal/model"
+	"github.com/OVantsevich/proxy-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// thresholdTrailingStop trailing stop threshold
+const thresholdTrailingStop = "trailing stop"
+
+// TrailingStopRepository store of trailing stops surviving restarts
+//
+//go:generate mockery --name=TrailingStopRepository --case=underscore --output=./mocks
+type TrailingStopRepository interface {
+	Save(ctx context.Context, stop *model.TrailingStop) error
+	Delete(ctx context.Context, positionID string) error
+	GetAll(ctx context.Context) ([]*model.TrailingStop, error)
+}
+
+// TrailingPriceService price feed of trailing stops
+//
+//go:generate mockery --name=TrailingPriceService --case=underscore --output=./mocks
+type TrailingPriceService interface {
+	GetCurrentPrices(ctx context.Context, names []string) (map[string]*model.CurrentPrice, error)
+	Subscribe(streamID uuid.UUID) *repository.Subscriber
+	UpdateSubscription(ctx context.Context, socketID uuid.UUID, names []string) ([]string, error)
+	SetConflation(socketID uuid.UUID, conflate bool) error
+}
+
+// TrailingStop service, follows prices of positions with trailing stops through conflating subscriber of price feed
+// and moves their stop losses in trading service
+type TrailingStop struct {
+	trailingStopRepository TrailingStopRepository
+	tradingRepository      TradingRepository
+	priceService           TrailingPriceService
+
+	streamID   uuid.UUID
+	subscriber *repository.Subscriber
+
+	mu    sync.Mutex
+	stops map[string]*model.TrailingStop
+	// ratchetMU orders stop loss updates, so older price never overwrites tighter stop loss
+	ratchetMU sync.Mutex
+}
+
+// NewTrailingStopService new trailing stop service with stops from store, they are followed until ctx is done
+func NewTrailingStopService(ctx context.Context, rps TrailingStopRepository, tr TradingRepository,
+	ps TrailingPriceService) (*TrailingStop, error) {
+	stops, err := rps.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("trailingStop - NewTrailingStopService - GetAll: %w", err)
+	}
+
+	t := &TrailingStop{
+		trailingStopRepository: rps,
+		tradingRepository:      tr,
+		priceService:           ps,
+		streamID:               uuid.New(),
+		stops:                  make(map[string]*model.TrailingStop, len(stops)),
+	}
+	for _, s := range stops {
+		t.stops[s.PositionID] = s
+	}
+	t.subscriber = ps.Subscribe(t.streamID)
+	err = ps.SetConflation(t.streamID, true)
+	if err != nil {
+		return nil, fmt.Errorf("trailingStop - NewTrailingStopService - SetConflation: %w", err)
+	}
+	t.syncNames(ctx)
+
+	go t.watch(ctx)
+	return t, nil
+}
+
+// Create trailing stop of open position at distance from market price or percent of it, replaces existing one,
+// stop loss already set for position is never loosened
+func (t *TrailingStop) Create(ctx context.Context, userID, positionID string, distance, percent float64) (*model.TrailingStop, error) {
+	position, err := t.tradingRepository.GetPositionByID(ctx, positionID)
+	if err != nil {
+		return nil, fmt.Errorf("trailingStop - Create - GetPositionByID: %w", err)
+	}
+	if !position.Open() {
+		return nil, &model.ThresholdError{Rule: rulePositionOpen, Threshold: thresholdTrailingStop, Reason: "can't be set for closed position"}
+	}
+
+	now := time.Now()
+	stop := &model.TrailingStop{
+		PositionID:    positionID,
+		User:          userID,
+		Name:          position.Name,
+		ShortPosition: position.ShortPosition,
+		Distance:      distance,
+		Percent:       percent,
+		StopLoss:      position.StopLoss,
+		Created:       now,
+		Updated:       now,
+	}
+	err = t.trailingStopRepository.Save(ctx, stop)
+	if err != nil {
+		return nil, fmt.Errorf("trailingStop - Create - Save: %w", err)
+	}
+	t.mu.Lock()
+	t.stops[positionID] = stop
+	t.mu.Unlock()
+	t.syncNames(ctx)
+
+	// stop follows the current price right away instead of waiting for the next update
+	prices, err := t.priceService.GetCurrentPrices(ctx, []string{position.Name})
+	if err != nil {
+		logrus.Warnf("trailingStop - Create - GetCurrentPrices: %v", err)
+	} else if price, ok := prices[position.Name]; ok {
+		t.ratchet(ctx, price.Price)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, ok := t.stops[positionID]; ok {
+		stop = current
+	}
+	created := *stop
+	return &created, nil
+}
+
+// GetUserStops trailing stops of user, oldest first
+func (t *TrailingStop) GetUserStops(_ context.Context, userID string) []*model.TrailingStop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stops := make([]*model.TrailingStop, 0)
+	for _, s := range t.stops {
+		if s.User == userID {
+			stop := *s
+			stops = append(stops, &stop)
+		}
+	}
+	sort.Slice(stops, func(i, j int) bool { return stops[i].Created.Before(stops[j].Created) })
+	return stops
+}
+
+// Cancel trailing stop of position, stop loss it has set stays
+func (t *TrailingStop) Cancel(ctx context.Context, positionID string) error {
+	t.mu.Lock()
+	_, ok := t.stops[positionID]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("trailingStop - Cancel: %w", model.ErrTrailingStopNotFound)
+	}
+
+	err := t.trailingStopRepository.Delete(ctx, positionID)
+	if err != nil {
+		return fmt.Errorf("trailingStop - Cancel - Delete: %w", err)
+	}
+	t.mu.Lock()
+	delete(t.stops, positionID)
+	t.mu.Unlock()
+	t.syncNames(ctx)
+	return nil
+}
+
+// watch move stop losses on price updates until ctx or subscriber is done
+func (t *TrailingStop) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.subscriber.Done():
+			return
+		case msg := <-t.subscriber.Messages():
+			if msg.Price != nil {
+				t.ratchet(ctx, msg.Price)
+			}
+		case <-t.subscriber.Changed():
+			for _, msg := range t.subscriber.DrainChanged() {
+				if msg.Price != nil {
+					t.ratchet(ctx, msg.Price)
+				}
+			}
+		}
+	}
+}
+
+// ratchet move stop losses of positions with price name if they get tighter at it,
+// stops of positions that were closed are removed
+func (t *TrailingStop) ratchet(ctx context.Context, price *model.Price) {
+	t.ratchetMU.Lock()
+	defer t.ratchetMU.Unlock()
+
+	t.mu.Lock()
+	var moved []*model.TrailingStop
+	for _, s := range t.stops {
+		if s.Name != price.Name {
+			continue
+		}
+		if next, ok := s.Next(price); ok {
+			stop := *s
+			stop.StopLoss = next
+			moved = append(moved, &stop)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, stop := range moved {
+		err := t.tradingRepository.SetStopLoss(ctx, stop.PositionID, stop.StopLoss)
+		if err != nil {
+			logrus.Warnf("trailingStop - ratchet - SetStopLoss: %v", err)
+			t.removeClosed(ctx, stop.PositionID)
+			continue
+		}
+		stop.Updated = time.Now()
+
+		t.mu.Lock()
+		current, ok := t.stops[stop.PositionID]
+		replaced := !ok || !current.Created.Equal(stop.Created)
+		if !replaced {
+			current.StopLoss, current.Updated = stop.StopLoss, stop.Updated
+		}
+		t.mu.Unlock()
+		if replaced {
+			continue
+		}
+		err = t.trailingStopRepository.Save(ctx, stop)
+		if err != nil {
+			// stop loss is set in trading service anyway, store gets it with the next move
+			logrus.Errorf("trailingStop - ratchet - Save: %v", err)
+		}
+	}
+}
+
+// removeClosed remove trailing stop of position if it's closed
+func (t *TrailingStop) removeClosed(ctx context.Context, positionID string) {
+	position, err := t.tradingRepository.GetPositionByID(ctx, positionID)
+	if err != nil {
+		logrus.Warnf("trailingStop - removeClosed - GetPositionByID: %v", err)
+		return
+	}
+	if position.Open() {
+		return
+	}
+	err = t.Cancel(ctx, positionID)
+	if err != nil {
+		logrus.Warnf("trailingStop - removeClosed - Cancel: %v", err)
+	}
+}
+
+// syncNames subscribe for prices of positions with trailing stops, newly subscribed ones are sent as snapshot
+func (t *TrailingStop) syncNames(ctx context.Context) {
+	t.mu.Lock()
+	names := make([]string, 0, len(t.stops))
+	for _, s := range t.stops {
+		names = append(names, s.Name)
+	}
+	t.mu.Unlock()
+
+	_, err := t.priceService.UpdateSubscription(ctx, t.streamID, names)
+	if err != nil {
+		logrus.Errorf("trailingStop - syncNames - UpdateSubscription: %v", err)
+	}
+}
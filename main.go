@@ -3,10 +3,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	prsProto "github.com/OVantsevich/Price-Service/proto"
 	"github.com/OVantsevich/proxy-service/internal/model"
+	"net"
 	"net/http"
+	"os/signal"
+	"syscall"
 
 	pasProto "github.com/OVantsevich/Payment-Service/proto"
 	tsProto "github.com/OVantsevich/Trading-Service/proto"
@@ -14,16 +18,22 @@ import (
 	_ "github.com/OVantsevich/proxy-service/docs"
 	"github.com/OVantsevich/proxy-service/internal/config"
 	"github.com/OVantsevich/proxy-service/internal/handler"
+	"github.com/OVantsevich/proxy-service/internal/metrics"
 	"github.com/OVantsevich/proxy-service/internal/repository"
+	"github.com/OVantsevich/proxy-service/internal/resilience"
 	"github.com/OVantsevich/proxy-service/internal/service"
+	"github.com/OVantsevich/proxy-service/internal/tracing"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v4"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	_ "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/sirupsen/logrus"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -54,20 +64,38 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 // @description Type "Bearer" followed by a space and JWT token.
 func main() {
 	logrus.Infof("Main enter")
-	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	cycleCtx, cancelCycle := context.WithCancel(context.Background())
+	defer cancelCycle()
 
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	cfg, err := config.NewMainConfig()
 	if err != nil {
 		logrus.Fatal(err)
 	}
-
-	connUser, err := grpc.Dial(fmt.Sprintf("%s:%s", cfg.UserServiceHost, cfg.UserServicePort), opts...)
+	shutdownTracing, err := tracing.Init(ctx, cfg)
 	if err != nil {
-		logrus.Fatal("Fatal Dial: ", err)
+		logrus.Fatal(err)
 	}
+
+	e := echo.New()
+	e.IPExtractor = ipExtractor(cfg.TrustedProxies)
+	e.Validator = &CustomValidator{validator: validator.New()}
+	e.HTTPErrorHandler = handler.ErrorHandler
+	e.Use(middleware.RequestID())
+
+	promMetrics := metrics.NewMetrics()
+	e.Use(otelecho.Middleware(cfg.ServiceName), promMetrics.Middleware())
+	e.GET("/metrics", echo.WrapHandler(promMetrics.Handler()))
+
+	var opts []grpc.DialOption
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor(), promMetrics.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor(), promMetrics.StreamClientInterceptor()),
+	)
+
+	connUser := dial(cfg, "user", cfg.UserServiceHost, cfg.UserServicePort, opts...)
 	usClient := usProto.NewUserServiceClient(connUser)
 	userRepository := repository.NewUserServiceRepository(usClient)
 	userService := service.NewUserService(userRepository)
@@ -76,7 +104,14 @@ func main() {
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	noAuthentication := e.Group("/auth")
+	rateLimitRepository := repository.NewRateLimitMemoryRepository(cycleCtx, cfg.RateLimitCleanupInterval)
+	rateLimitService, err := service.NewRateLimitService(rateLimitRepository, cfg.RateLimits)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	rateLimit := handler.NewRateLimitHandler(rateLimitService)
+
+	noAuthentication := e.Group("/auth", rateLimit.ByIP())
 	noAuthentication.POST("/signup", userHandler.Signup)
 	noAuthentication.POST("/login", userHandler.Login)
 	noAuthentication.GET("/refresh", userHandler.Refresh)
@@ -93,61 +128,196 @@ func main() {
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(model.CustomClaims)
 		},
-	}))
+	}), rateLimit.ByUser())
 
-	withAuthentication.PUT("/update", userHandler.Update)
-	withAuthentication.GET("/userByID", userHandler.UserByID)
+	rbac := handler.NewRBAC(cfg.RolePermissions, cfg.DefaultRole)
+	withAuthentication.PUT("/update", userHandler.Update, rbac.Require(model.PermissionProfile))
+	withAuthentication.GET("/userByID", userHandler.UserByID, rbac.Require(model.PermissionUsersRead))
 
-	connPayment, err := grpc.Dial(fmt.Sprintf("%s:%s", cfg.PaymentServiceHost, cfg.PaymentServicePort), opts...)
-	if err != nil {
-		logrus.Fatal("Fatal Dial: ", err)
-	}
+	admin := withAuthentication.Group("/admin", rbac.Require(model.PermissionAdmin))
+	admin.GET("/userByID", userHandler.UserByID, rbac.Require(model.PermissionUsersRead))
+
+	connPayment := dial(cfg, "payment", cfg.PaymentServiceHost, cfg.PaymentServicePort, opts...)
 	psClient := pasProto.NewPaymentServiceClient(connPayment)
 	accountRepository := repository.NewPaymentServiceRepository(psClient)
+
+	connTrading := dial(cfg, "trading", cfg.TradingServiceHost, cfg.TradingServicePort, opts...)
+	tsClient := tsProto.NewTradingServiceClient(connTrading)
+	tradingRepository, err := repository.NewTradingServiceRepository(tsClient)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	ownershipService := service.NewOwnershipService(tradingRepository, accountRepository)
+	authorization := handler.NewAuthorization(ownershipService, rbac, cfg.OwnershipDenyStatus)
+
 	accountService := service.NewAccountService(accountRepository)
-	accountHandler := handler.NewAccountHandler(accountService)
+	accountHandler := handler.NewAccountHandler(accountService, authorization)
 	logrus.Infof("account handler started")
 
-	withAuthentication.POST("/createAccount", accountHandler.CreateAccount)
-	withAuthentication.GET("/getUserAccount", accountHandler.GetUserAccount)
-	withAuthentication.POST("/increaseAmount", accountHandler.IncreaseAmount)
-	withAuthentication.POST("/decreaseAmount", accountHandler.DecreaseAmount)
+	withAuthentication.POST("/createAccount", accountHandler.CreateAccount, rbac.Require(model.PermissionAccounts))
+	withAuthentication.GET("/getUserAccount", accountHandler.GetUserAccount, rbac.Require(model.PermissionAccounts))
+	withAuthentication.POST("/increaseAmount", accountHandler.IncreaseAmount, rbac.Require(model.PermissionAccounts))
+	withAuthentication.POST("/decreaseAmount", accountHandler.DecreaseAmount, rbac.Require(model.PermissionAccounts))
 
-	connPrice, err := grpc.Dial(fmt.Sprintf("%s:%s", cfg.PriceServiceHost, cfg.PriceServicePort), opts...)
+	connPrice := dial(cfg, "price", cfg.PriceServiceHost, cfg.PriceServicePort, opts...)
+	prsClient := prsProto.NewPriceServiceClient(connPrice)
+	priceRepository, err := repository.NewPriceServiceRepository(cycleCtx, prsClient, cfg.PriceStreamMinBackoff, cfg.PriceStreamMaxBackoff)
 	if err != nil {
-		logrus.Fatal("Fatal Dial: ", err)
+		logrus.Fatal(err)
 	}
-	prsClient := prsProto.NewPriceServiceClient(connPrice)
-	priceRepository, err := repository.NewPriceServiceRepository(context.Background(), prsClient)
+	priceCache := repository.NewPriceCacheRepository(cfg.PriceCacheMaxSymbols)
+	listenersRepository := repository.NewListenersRepository()
+	candleRepository, err := repository.NewCandleRepository(cfg.CandleIntervals, cfg.CandleRetention, cfg.CandleMaxSymbols)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	historyRepository := repository.NewPriceHistoryRepository(cfg.PriceHistorySize, cfg.PriceHistoryMaxSymbols)
+	priceService, err := service.NewPriceService(cycleCtx, priceRepository, priceCache, candleRepository, historyRepository,
+		listenersRepository, service.PriceConfig{
+			SlowConsumerPolicy: cfg.SlowConsumerPolicy,
+			CacheMaxAge:        cfg.PriceCacheMaxAge,
+			PinnedNames:        cfg.PricePinnedNames,
+			MaxReplay:          cfg.PriceResumeMaxReplay,
+		})
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	priceService := service.NewPriceService(context.Background(), priceRepository, repository.NewListenersRepository())
-	priceHandler := handler.NewPriceHandler(priceService)
+	promMetrics.RegisterPriceStream(listenersRepository, priceService)
+	tradingService := service.NewTradingService(tradingRepository, priceService, cfg.ThresholdMinDistancePercent, cfg.BackendTimeout)
+	priceHandler := handler.NewPriceHandler(priceService, tradingService, cfg.PriceMaxFrameRate, cfg.PriceHeartbeat, cfg.PnLRefreshInterval,
+		handler.WebsocketConfig{
+			PingInterval:   cfg.WebsocketPingInterval,
+			PongTimeout:    cfg.WebsocketPongTimeout,
+			WriteTimeout:   cfg.WebsocketWriteTimeout,
+			MaxMessageSize: cfg.WebsocketMaxMessageSize,
+		})
 	logrus.Infof("price handler started")
 
-	withAuthentication.POST("/getCurrentPrices", priceHandler.GetCurrentPrices)
-	withAuthentication.GET("/subscribe", priceHandler.Subscribe)
+	withAuthentication.POST("/getCurrentPrices", priceHandler.GetCurrentPrices, rbac.Require(model.PermissionPrices))
+	withAuthentication.GET("/subscribe", priceHandler.Subscribe, rbac.Require(model.PermissionPrices))
+	withAuthentication.GET("/prices/stream", priceHandler.Stream, rbac.Require(model.PermissionPrices))
+	withAuthentication.GET("/prices/candles", priceHandler.GetCandles, rbac.Require(model.PermissionPrices))
+	withAuthentication.GET("/prices/history", priceHandler.GetHistory, rbac.Require(model.PermissionPrices))
 
-	connTrading, err := grpc.Dial(fmt.Sprintf("%s:%s", cfg.TradingServiceHost, cfg.TradingServicePort), opts...)
+	tradingHandler := handler.NewTradingHandler(tradingService, authorization)
+	logrus.Infof("trading handler started")
+
+	withAuthentication.POST("/openPosition", tradingHandler.OpenPosition, rbac.Require(model.PermissionTrading))
+	withAuthentication.GET("/getUserPositions", tradingHandler.GetUserPositions, rbac.Require(model.PermissionTrading))
+	withAuthentication.GET("/positions/pnl", priceHandler.PositionsPnL, rbac.Require(model.PermissionTrading, model.PermissionPrices))
+	withAuthentication.GET("/getPositionByID", tradingHandler.GetPositionByID, rbac.Require(model.PermissionTrading))
+	withAuthentication.POST("/setTakeProfit", tradingHandler.SetTakeProfit, rbac.Require(model.PermissionTrading))
+	withAuthentication.POST("/setStopLoss", tradingHandler.SetStopLoss, rbac.Require(model.PermissionTrading))
+	withAuthentication.POST("/closePosition", tradingHandler.ClosePosition, rbac.Require(model.PermissionTrading))
+
+	trailingStopRepository, err := repository.NewTrailingStopFileRepository(cfg.TrailingStopsFile)
 	if err != nil {
-		logrus.Fatal("Fatal Dial: ", err)
+		logrus.Fatal(err)
 	}
-	tsClient := tsProto.NewTradingServiceClient(connTrading)
-	tradingRepository, err := repository.NewTradingServiceRepository(tsClient)
+	trailingStopService, err := service.NewTrailingStopService(cycleCtx, trailingStopRepository, tradingRepository, priceService)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	tradingService := service.NewTradingService(tradingRepository)
-	tradingHandler := handler.NewTradingHandler(tradingService)
-	logrus.Infof("trading handler started")
+	trailingStopHandler := handler.NewTrailingStopHandler(trailingStopService, authorization)
+	logrus.Infof("trailing stop handler started")
+
+	withAuthentication.POST("/createTrailingStop", trailingStopHandler.CreateTrailingStop, rbac.Require(model.PermissionTrading))
+	withAuthentication.GET("/getTrailingStops", trailingStopHandler.GetTrailingStops, rbac.Require(model.PermissionTrading))
+	withAuthentication.POST("/cancelTrailingStop", trailingStopHandler.CancelTrailingStop, rbac.Require(model.PermissionTrading))
 
-	withAuthentication.POST("/openPosition", tradingHandler.OpenPosition)
-	withAuthentication.GET("/getUserPositions", tradingHandler.GetUserPositions)
-	withAuthentication.GET("/getPositionByID", tradingHandler.GetPositionByID)
-	withAuthentication.POST("/setTakeProfit", tradingHandler.SetTakeProfit)
-	withAuthentication.POST("/setStopLoss", tradingHandler.SetStopLoss)
-	withAuthentication.POST("/closePosition", tradingHandler.ClosePosition)
+	portfolioService := service.NewPortfolioService(accountRepository, tradingRepository, priceService)
+	portfolioHandler := handler.NewPortfolioHandler(portfolioService)
+	logrus.Infof("portfolio handler started")
+
+	withAuthentication.GET("/portfolio", portfolioHandler.GetPortfolio, rbac.Require(model.PermissionTrading, model.PermissionAccounts))
+
+	healthService := service.NewHealthService(priceRepository, cfg.HealthCheckTimeout,
+		backendHealth(cfg, "user", connUser),
+		backendHealth(cfg, "payment", connPayment),
+		backendHealth(cfg, "price", connPrice),
+		backendHealth(cfg, "trading", connTrading),
+	)
+	healthHandler := handler.NewHealthHandler(healthService)
+	e.GET("/healthz", healthHandler.Liveness)
+	e.GET("/readyz", healthHandler.Readiness)
+
+	go func() {
+		err := e.Start(fmt.Sprintf(":%s", cfg.Port))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdown(cfg, e, priceHandler, shutdownTracing, cancelCycle, connUser, connPayment, connPrice, connTrading)
+}
+
+// ipExtractor client ip from connection, or from X-Forwarded-For if request came through trusted proxy,
+// headers of other clients are ignored, so they can't pick their ip, exit on invalid CIDR
+func ipExtractor(proxies []string) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			logrus.Fatalf("main - ipExtractor - ParseCIDR: %v", err)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
 
-	logrus.Fatal(e.Start(fmt.Sprintf(":%s", cfg.Port)))
+// dial connect to backend guarded by own circuit breaker, exit on failure
+func dial(cfg *config.MainConfig, name, host, port string, opts ...grpc.DialOption) *grpc.ClientConn {
+	opts = append(append([]grpc.DialOption{}, opts...), resilience.NewBackend(name, cfg).DialOption())
+	conn, err := grpc.Dial(fmt.Sprintf("%s:%s", host, port), opts...)
+	if err != nil {
+		logrus.Fatal("Fatal Dial: ", err)
+	}
+	return conn
+}
+
+// backendHealth health repository for backend connection, required if listed in config
+func backendHealth(cfg *config.MainConfig, name string, conn *grpc.ClientConn) *repository.GRPCHealth {
+	required := false
+	for _, r := range cfg.HealthRequiredBackends {
+		if r == name {
+			required = true
+		}
+	}
+	return repository.NewGRPCHealthRepository(name, required, conn)
+}
+
+// shutdown close price streams, stop accepting requests, wait for in-flight requests and close backend connections,
+// streams are closed first, otherwise server would wait for event streams until timeout
+func shutdown(cfg *config.MainConfig, e *echo.Echo, priceHandler *handler.Price, shutdownTracing func(context.Context) error,
+	cancelCycle context.CancelFunc, conns ...*grpc.ClientConn) {
+	logrus.Infof("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err := priceHandler.Shutdown(ctx)
+	if err != nil {
+		logrus.Errorf("shutdown - priceHandler.Shutdown: %v", err)
+	}
+	err = e.Shutdown(ctx)
+	if err != nil {
+		logrus.Errorf("shutdown - Shutdown: %v", err)
+	}
+
+	cancelCycle()
+	for _, conn := range conns {
+		err = conn.Close()
+		if err != nil {
+			logrus.Errorf("shutdown - Close: %v", err)
+		}
+	}
+	err = shutdownTracing(ctx)
+	if err != nil {
+		logrus.Errorf("shutdown - shutdownTracing: %v", err)
+	}
+	logrus.Infof("shutdown completed")
 }